@@ -0,0 +1,131 @@
+package jsonflatten
+
+// ArrayMode selects how arrays are flattened.
+type ArrayMode int
+
+const (
+	// ArrayIndex emits each element with its index as key. This is the
+	// default.
+	ArrayIndex ArrayMode = iota
+	// ArrayRaw emits the whole array as a string with its JSON encoding.
+	ArrayRaw
+	// ArrayJoin emits the array as a string with the elements joined by the
+	// array separator. Strings are not quoted and nested objects or arrays
+	// are encoded as JSON.
+	ArrayJoin
+	// ArrayWildcard uses the same key, "[]", for every element so the same
+	// flattened key is emitted once per element.
+	ArrayWildcard
+	// ArrayDrop does not emit arrays nor any value inside them.
+	ArrayDrop
+)
+
+const wildcardKey = "[]"
+
+// WithArrayMode sets how arrays are flattened. The root array does not have
+// a key to hold a single value so ArrayRaw and ArrayJoin flatten it by index.
+func WithArrayMode(mode ArrayMode) Option {
+	return func(o *options) {
+		o.arrayMode = mode
+	}
+}
+
+// WithArraySeparator sets the string used to join elements with ArrayJoin.
+// By default it is ",".
+func WithArraySeparator(sep string) Option {
+	return func(o *options) {
+		o.arraySeparator = sep
+	}
+}
+
+// capture accumulates the values of an array that is emitted as a single
+// value instead of being flattened.
+type capture struct {
+	mode      ArrayMode
+	sep       string
+	level     int
+	buf       []byte
+	needComma bool
+}
+
+func newCapture(mode ArrayMode, sep string, level int) *capture {
+	c := &capture{
+		mode:  mode,
+		sep:   sep,
+		level: level,
+	}
+
+	if mode == ArrayRaw {
+		c.buf = append(c.buf, '[')
+	}
+
+	return c
+}
+
+func (c *capture) prefix(s *State, name string, top bool) {
+	if c.needComma {
+		if top && c.mode == ArrayJoin {
+			c.buf = append(c.buf, c.sep...)
+		} else {
+			c.buf = append(c.buf, ',')
+		}
+	}
+
+	if s.jsonType == TypeObject {
+		c.buf = appendQuoted(c.buf, name)
+		c.buf = append(c.buf, ':')
+	}
+}
+
+func (c *capture) open(s *State, name string, t Type, top bool) {
+	if c.mode == ArrayDrop {
+		return
+	}
+
+	c.prefix(s, name, top)
+	if t == TypeObject {
+		c.buf = append(c.buf, '{')
+	} else {
+		c.buf = append(c.buf, '[')
+	}
+	c.needComma = false
+}
+
+func (c *capture) close(t Type) {
+	if c.mode == ArrayDrop {
+		return
+	}
+
+	if t == TypeObject {
+		c.buf = append(c.buf, '}')
+	} else {
+		c.buf = append(c.buf, ']')
+	}
+	c.needComma = true
+}
+
+func (c *capture) value(s *State, name string, v Value, top bool) {
+	if c.mode == ArrayDrop {
+		return
+	}
+
+	c.prefix(s, name, top)
+	if top && c.mode == ArrayJoin {
+		c.buf = appendText(c.buf, v)
+	} else {
+		c.buf = appendJSON(c.buf, v)
+	}
+	c.needComma = true
+}
+
+// finish returns the captured value and false if nothing has to be emitted.
+func (c *capture) finish() (string, bool) {
+	switch c.mode {
+	case ArrayDrop:
+		return "", false
+	case ArrayRaw:
+		c.buf = append(c.buf, ']')
+	}
+
+	return string(c.buf), true
+}
@@ -0,0 +1,94 @@
+package jsonflatten
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const arraysJson = `{
+	"a": [1, "two", true, null],
+	"b": [{"c": "d"}, [1, 2]],
+	"e": {"f": []}
+}`
+
+func TestArrayModes(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     []Option
+		expected []pair
+	}{
+		{
+			name: "index",
+			expected: []pair{
+				{"a.0", float64(1)},
+				{"a.1", "two"},
+				{"a.2", true},
+				{"a.3", nil},
+				{"b.0.c", "d"},
+				{"b.1.0", float64(1)},
+				{"b.1.1", float64(2)},
+			},
+		},
+		{
+			name: "raw",
+			opts: []Option{WithArrayMode(ArrayRaw)},
+			expected: []pair{
+				{"a", `[1,"two",true,null]`},
+				{"b", `[{"c":"d"},[1,2]]`},
+				{"e.f", `[]`},
+			},
+		},
+		{
+			name: "join",
+			opts: []Option{
+				WithArrayMode(ArrayJoin),
+				WithArraySeparator("|"),
+			},
+			expected: []pair{
+				{"a", `1|two|true|null`},
+				{"b", `{"c":"d"}|[1,2]`},
+				{"e.f", ``},
+			},
+		},
+		{
+			name: "wildcard",
+			opts: []Option{WithArrayMode(ArrayWildcard)},
+			expected: []pair{
+				{"a.[]", float64(1)},
+				{"a.[]", "two"},
+				{"a.[]", true},
+				{"a.[]", nil},
+				{"b.[].[]", float64(1)},
+				{"b.[].[]", float64(2)},
+				{"b.[].c", "d"},
+			},
+		},
+		{
+			name: "drop",
+			opts: []Option{WithArrayMode(ArrayDrop)},
+		},
+	}
+
+	for _, test := range tests {
+		for name, f := range flatteners {
+			t.Run(test.name+"/"+name, func(t *testing.T) {
+				pairs, err := collect(t, f, arraysJson, test.opts...)
+				require.NoError(t, err)
+				require.Equal(t, test.expected, pairs)
+			})
+		}
+	}
+}
+
+func TestArrayModesRoot(t *testing.T) {
+	doc := `[{"a": [1, 2]}, 3]`
+
+	pairs, err := collect(t, flatteners["pitr"], doc, WithArrayMode(ArrayRaw))
+	require.NoError(t, err)
+	require.Equal(t, []pair{{"0.a", "[1,2]"}, {"1", float64(3)}}, pairs)
+
+	pairs, err = collect(t, flatteners["pitr"], doc, WithArrayMode(ArrayDrop))
+	require.NoError(t, err)
+	require.Empty(t, pairs)
+}
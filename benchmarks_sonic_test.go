@@ -0,0 +1,76 @@
+//go:build sonic
+
+package jsonflatten
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/bytedance/sonic"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	sonicBenchmarks["small"] = benchmarkSmallSonic
+	sonicBenchmarks["big"] = benchmarkBigSonic
+	sonicBenchmarks["unmarshal-small"] = benchmarkUnmarshalSmallSonic
+	sonicBenchmarks["unmarshal-big"] = benchmarkUnmarshalBigSonic
+}
+
+func benchmarkSmallSonic(b *testing.B) {
+	r := strings.NewReader(testJson)
+
+	for b.Loop() {
+		_, err := r.Seek(0, io.SeekStart)
+		require.NoError(b, err)
+
+		emitter := func(k string, v any) bool {
+			return true
+		}
+		p := NewSonic(emitter)
+
+		err = p.Parse(r)
+		require.NoError(b, err)
+	}
+}
+
+func benchmarkBigSonic(b *testing.B) {
+	f := bytes.NewReader(bigDoc())
+
+	for b.Loop() {
+		_, err := f.Seek(0, io.SeekStart)
+		require.NoError(b, err)
+
+		emitter := func(k string, v any) bool {
+			return true
+		}
+		p := NewSonic(emitter)
+
+		err = p.Parse(f)
+		require.NoError(b, err)
+	}
+}
+
+func benchmarkUnmarshalSmallSonic(b *testing.B) {
+	for b.Loop() {
+		var m any
+		err := sonic.Unmarshal([]byte(testJson), &m)
+		require.NoError(b, err)
+	}
+}
+
+func benchmarkUnmarshalBigSonic(b *testing.B) {
+	f := bytes.NewReader(bigDoc())
+
+	for b.Loop() {
+		_, err := f.Seek(0, io.SeekStart)
+		require.NoError(b, err)
+
+		var m any
+		decoder := sonic.ConfigDefault.NewDecoder(f)
+		err = decoder.Decode(&m)
+		require.NoError(b, err)
+	}
+}
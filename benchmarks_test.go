@@ -1,44 +1,185 @@
 package jsonflatten
 
 import (
+	"bytes"
 	"encoding/json"
 	"io"
+	"maps"
 	"os"
+	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
 	"testing"
 
-	"github.com/bytedance/sonic"
 	jsonv2 "github.com/go-json-experiment/json"
 	"github.com/go-json-experiment/json/jsontext"
+	"github.com/jfontan/jsonflatten/generate"
 	"github.com/stretchr/testify/require"
 )
 
+// bigShape generates an array of 40000 objects, about 25 MB.
+var bigShape = generate.Shape{
+	Seed:        1,
+	Records:     40000,
+	Depth:       4,
+	Width:       10,
+	ArrayLength: 4,
+	StringSize:  24,
+	Escapes:     0.05,
+}
+
+// bigDoc is the document used by the Big benchmarks. It is large-file.json,
+// the document of the results in bench_results, when it is checked out with
+// git-lfs, otherwise it is generated with bigShape. It is read once.
+var bigDoc = sync.OnceValue(func() []byte {
+	b, err := os.ReadFile("large-file.json")
+	if err == nil && !bytes.HasPrefix(b, []byte("version https://git-lfs")) {
+		return b
+	}
+
+	return generate.Generate(bigShape)
+})
+
+// bigFile writes bigDoc to a temporary file and returns its path.
+func bigFile(tb testing.TB) string {
+	tb.Helper()
+
+	path := filepath.Join(tb.TempDir(), "big.json")
+	err := os.WriteFile(path, bigDoc(), 0o644)
+	require.NoError(tb, err)
+
+	return path
+}
+
+// shapes are the documents of BenchmarkShapes.
+var shapes = []struct {
+	name  string
+	shape generate.Shape
+}{
+	{
+		name: "objects",
+		shape: generate.Shape{
+			Seed: 1, Records: 2000, Depth: 6, Width: 8, ArrayLength: 4,
+			StringSize: 16,
+		},
+	},
+	{
+		name: "deep",
+		shape: generate.Shape{
+			Seed: 1, Records: 1000, Depth: 64, Width: 2, StringSize: 8,
+			Mix: generate.Mix{Objects: 1, Integers: 1},
+		},
+	},
+	{
+		name: "wide",
+		shape: generate.Shape{
+			Seed: 1, Records: 100, Depth: 2, Width: 1000, StringSize: 16,
+		},
+	},
+	{
+		name: "arrays",
+		shape: generate.Shape{
+			Seed: 1, Records: 100, Depth: 3, Width: 2, ArrayLength: 1000,
+			Mix: generate.Mix{Arrays: 1, Integers: 1, Floats: 1},
+		},
+	},
+	{
+		name: "strings",
+		shape: generate.Shape{
+			Seed: 1, Records: 1000, Depth: 2, Width: 8, StringSize: 512,
+			Escapes: 0.3, Mix: generate.Mix{Strings: 1},
+		},
+	},
+	{
+		name: "numbers",
+		shape: generate.Shape{
+			Seed: 1, Records: 5000, Depth: 2, Width: 8, ArrayLength: 16,
+			Mix: generate.Mix{Arrays: 1, Integers: 2, Floats: 2, Exponents: 1},
+		},
+	},
+}
+
+// BenchmarkShapes flattens generated documents of several shapes with all
+// the flatteners.
+func BenchmarkShapes(b *testing.B) {
+	for _, s := range shapes {
+		doc := generate.Generate(s.shape)
+
+		for _, name := range slices.Sorted(maps.Keys(flatteners)) {
+			f := flatteners[name]
+			if name == "parallel" {
+				// the test flattener uses one element per chunk
+				f = func(e Emitter, o ...Option) flattener {
+					return NewParallel(e, o...)
+				}
+			}
+
+			b.Run("shape="+s.name+"/parser="+name, func(b *testing.B) {
+				b.SetBytes(int64(len(doc)))
+				emitter := func(k string, v any) bool {
+					return true
+				}
+
+				for b.Loop() {
+					err := f(emitter).Parse(bytes.NewReader(doc))
+					require.NoError(b, err)
+				}
+			})
+		}
+	}
+}
+
 func BenchmarkSmall(b *testing.B) {
 	b.Run("parser=v1", benchmarkSmallParser)
 	b.Run("parser=v2", benchmarkSmallParserV2)
 	b.Run("parser=pitr", benchmarkSmallParserPitr)
+	b.Run("parser=pitr-reuse", benchmarkSmallParserPitrReuse)
+	b.Run("parser=pitr-raw", benchmarkSmallParserPitrRaw)
+	b.Run("parser=fast", benchmarkSmallParserFast)
+	b.Run("parser=pitr-typed", benchmarkSmallParserPitrTyped)
 	b.Run("parser=memory", benchmarkSmallMemory)
-	b.Run("parser=sonic", benchmarkSmallSonic)
+	runSonic(b, "small")
 }
 
 func BenchmarkBig(b *testing.B) {
 	b.Run("parser=v1", benchmarkBigParser)
 	b.Run("parser=v2", benchmarkBigParserV2)
 	b.Run("parser=pitr", benchmarkBigParserPitr)
+	b.Run("parser=pitr-reuse", benchmarkBigParserPitrReuse)
+	b.Run("parser=pitr-raw", benchmarkBigParserPitrRaw)
+	b.Run("parser=pitr-typed", benchmarkBigParserPitrTyped)
+	b.Run("parser=pitr-file", benchmarkBigParserPitrFile)
+	b.Run("parser=fast", benchmarkBigParserFast)
+	b.Run("parser=fast-file", benchmarkBigParserFastFile)
+	b.Run("parser=parallel", benchmarkBigParallel)
+	b.Run("parser=parallel-file", benchmarkBigParallelFile)
+	b.Run("parser=parallel-unordered", benchmarkBigParallelUnordered)
 	b.Run("parser=memory", benchmarkBigMemory)
-	b.Run("parser=sonic", benchmarkBigSonic)
+	runSonic(b, "big")
 }
 
 func BenchmarkUnmarshalSmall(b *testing.B) {
 	b.Run("parser=v1", benchmarkUnmarshalSmall)
 	b.Run("parser=v2", benchmarkUnmarshalSmallV2)
-	b.Run("parser=sonic", benchmarkUnmarshalSmallSonic)
+	runSonic(b, "unmarshal-small")
 }
 
 func BenchmarkUnmarshalBig(b *testing.B) {
 	b.Run("parser=v1", benchmarkUnmarshalBig)
 	b.Run("parser=v2", benchmarkUnmarshalBigV2)
-	b.Run("parser=sonic", benchmarkUnmarshalBigSonic)
+	runSonic(b, "unmarshal-big")
+}
+
+// sonicBenchmarks has the sonic benchmarks of each group. They are added by
+// benchmarks_sonic_test.go with the sonic build tag.
+var sonicBenchmarks = map[string]func(*testing.B){}
+
+// runSonic runs the sonic benchmark of the group if it is built.
+func runSonic(b *testing.B, group string) {
+	if f, ok := sonicBenchmarks[group]; ok {
+		b.Run("parser=sonic", f)
+	}
 }
 
 func benchmarkSmallParser(b *testing.B) {
@@ -92,31 +233,82 @@ func benchmarkSmallParserPitr(b *testing.B) {
 	}
 }
 
-func benchmarkSmallMemory(b *testing.B) {
+func benchmarkSmallParserPitrReuse(b *testing.B) {
 	r := strings.NewReader(testJson)
+	emitter := func(k string, v any) bool {
+		return true
+	}
+	p := NewParserPitr(emitter)
 
 	for b.Loop() {
 		_, err := r.Seek(0, io.SeekStart)
 		require.NoError(b, err)
 
-		p := new(Memory)
-		p.emitter = func(k string, v any) bool {
-			return true
-		}
+		p.Reset(emitter)
+		err = p.Parse(r)
+		require.NoError(b, err)
+	}
+}
+
+func benchmarkSmallParserPitrRaw(b *testing.B) {
+	r := strings.NewReader(testJson)
+	emitter := func(k []byte, v Value) bool {
+		return true
+	}
+	p := NewParserPitr(nil, WithRawEmitter(emitter))
 
+	for b.Loop() {
+		_, err := r.Seek(0, io.SeekStart)
+		require.NoError(b, err)
+
+		p.Reset(nil)
 		err = p.Parse(r)
 		require.NoError(b, err)
 	}
 }
 
-func benchmarkSmallMemoryV2(b *testing.B) {
+func benchmarkSmallParserPitrTyped(b *testing.B) {
 	r := strings.NewReader(testJson)
+	emitter := func(k string, v Value) bool {
+		return true
+	}
+	p := NewParserPitr(nil, WithTypedEmitter(emitter))
 
 	for b.Loop() {
 		_, err := r.Seek(0, io.SeekStart)
 		require.NoError(b, err)
 
-		p := new(MemoryV2)
+		p.Reset(nil)
+		err = p.Parse(r)
+		require.NoError(b, err)
+	}
+}
+
+func benchmarkSmallParserFast(b *testing.B) {
+	r := strings.NewReader(testJson)
+	emitter := func(k string, v any) bool {
+		return true
+	}
+	p := NewParserFast(emitter)
+
+	for b.Loop() {
+		_, err := r.Seek(0, io.SeekStart)
+		require.NoError(b, err)
+
+		p.Reset(emitter)
+		err = p.Parse(r)
+		require.NoError(b, err)
+	}
+}
+
+func benchmarkSmallMemory(b *testing.B) {
+	r := strings.NewReader(testJson)
+
+	for b.Loop() {
+		_, err := r.Seek(0, io.SeekStart)
+		require.NoError(b, err)
+
+		p := new(Memory)
 		p.emitter = func(k string, v any) bool {
 			return true
 		}
@@ -126,22 +318,23 @@ func benchmarkSmallMemoryV2(b *testing.B) {
 	}
 }
 
-func benchmarkSmallSonic(b *testing.B) {
+func benchmarkSmallMemoryV2(b *testing.B) {
 	r := strings.NewReader(testJson)
 
 	for b.Loop() {
 		_, err := r.Seek(0, io.SeekStart)
 		require.NoError(b, err)
 
-		emitter := func(k string, v any) bool {
+		p := new(MemoryV2)
+		p.emitter = func(k string, v any) bool {
 			return true
 		}
-		p := NewSonic(emitter)
 
 		err = p.Parse(r)
 		require.NoError(b, err)
 	}
 }
+
 func benchmarkUnmarshalSmall(b *testing.B) {
 	for b.Loop() {
 		var m any
@@ -158,18 +351,8 @@ func benchmarkUnmarshalSmallV2(b *testing.B) {
 	}
 }
 
-func benchmarkUnmarshalSmallSonic(b *testing.B) {
-	for b.Loop() {
-		var m any
-		err := sonic.Unmarshal([]byte(testJson), &m)
-		require.NoError(b, err)
-	}
-}
-
 func benchmarkBigParser(b *testing.B) {
-	f, err := os.Open("large-file.json")
-	require.NoError(b, err)
-	defer f.Close()
+	f := bytes.NewReader(bigDoc())
 
 	for b.Loop() {
 		_, err := f.Seek(0, io.SeekStart)
@@ -186,9 +369,7 @@ func benchmarkBigParser(b *testing.B) {
 }
 
 func benchmarkBigParserV2(b *testing.B) {
-	f, err := os.Open("large-file.json")
-	require.NoError(b, err)
-	defer f.Close()
+	f := bytes.NewReader(bigDoc())
 
 	for b.Loop() {
 		_, err := f.Seek(0, io.SeekStart)
@@ -205,9 +386,7 @@ func benchmarkBigParserV2(b *testing.B) {
 }
 
 func benchmarkBigParserPitr(b *testing.B) {
-	f, err := os.Open("large-file.json")
-	require.NoError(b, err)
-	defer f.Close()
+	f := bytes.NewReader(bigDoc())
 
 	for b.Loop() {
 		_, err := f.Seek(0, io.SeekStart)
@@ -223,87 +402,187 @@ func benchmarkBigParserPitr(b *testing.B) {
 	}
 }
 
-func benchmarkBigMemory(b *testing.B) {
-	f, err := os.Open("large-file.json")
-	require.NoError(b, err)
-	defer f.Close()
+func benchmarkBigParserPitrReuse(b *testing.B) {
+	f := bytes.NewReader(bigDoc())
+
+	emitter := func(k string, v any) bool {
+		return true
+	}
+	p := NewParserPitr(emitter)
 
 	for b.Loop() {
 		_, err := f.Seek(0, io.SeekStart)
 		require.NoError(b, err)
 
-		p := new(Memory)
-		p.emitter = func(k string, v any) bool {
-			return true
-		}
+		p.Reset(emitter)
+		err = p.Parse(f)
+		require.NoError(b, err)
+	}
+}
+
+func benchmarkBigParserPitrRaw(b *testing.B) {
+	f := bytes.NewReader(bigDoc())
 
+	emitter := func(k []byte, v Value) bool {
+		return true
+	}
+	p := NewParserPitr(nil, WithRawEmitter(emitter))
+
+	for b.Loop() {
+		_, err := f.Seek(0, io.SeekStart)
+		require.NoError(b, err)
+
+		p.Reset(nil)
 		err = p.Parse(f)
 		require.NoError(b, err)
 	}
 }
 
-func benchmarkBigSonic(b *testing.B) {
-	f, err := os.Open("large-file.json")
-	require.NoError(b, err)
-	defer f.Close()
+func benchmarkBigParserPitrTyped(b *testing.B) {
+	f := bytes.NewReader(bigDoc())
+
+	emitter := func(k string, v Value) bool {
+		return true
+	}
+	p := NewParserPitr(nil, WithTypedEmitter(emitter))
 
 	for b.Loop() {
 		_, err := f.Seek(0, io.SeekStart)
 		require.NoError(b, err)
 
-		emitter := func(k string, v any) bool {
-			return true
-		}
-		p := NewSonic(emitter)
+		p.Reset(nil)
+		err = p.Parse(f)
+		require.NoError(b, err)
+	}
+}
+
+func benchmarkBigParserFast(b *testing.B) {
+	f := bytes.NewReader(bigDoc())
+
+	emitter := func(k string, v any) bool {
+		return true
+	}
+	p := NewParserFast(emitter)
+
+	for b.Loop() {
+		_, err := f.Seek(0, io.SeekStart)
+		require.NoError(b, err)
 
+		p.Reset(emitter)
 		err = p.Parse(f)
 		require.NoError(b, err)
 	}
 }
-func benchmarkUnmarshalBig(b *testing.B) {
-	f, err := os.Open("large-file.json")
-	require.NoError(b, err)
-	defer f.Close()
+
+func benchmarkBigParserFastFile(b *testing.B) {
+	emitter := func(k string, v any) bool {
+		return true
+	}
+	path := bigFile(b)
+	p := NewParserFast(emitter)
+
+	for b.Loop() {
+		p.Reset(emitter)
+		err := p.ParseFile(path)
+		require.NoError(b, err)
+	}
+}
+
+func benchmarkBigParallel(b *testing.B) {
+	benchmarkBigParallelOptions(b)
+}
+
+func benchmarkBigParallelUnordered(b *testing.B) {
+	benchmarkBigParallelOptions(b, WithUnordered(true))
+}
+
+func benchmarkBigParallelOptions(b *testing.B, opts ...Option) {
+	f := bytes.NewReader(bigDoc())
+
+	emitter := func(k string, v any) bool {
+		return true
+	}
+	p := NewParallel(emitter, opts...)
 
 	for b.Loop() {
 		_, err := f.Seek(0, io.SeekStart)
 		require.NoError(b, err)
 
-		var m any
-		decoder := json.NewDecoder(f)
-		err = decoder.Decode(&m)
+		p.Reset(emitter)
+		err = p.Parse(f)
 		require.NoError(b, err)
 	}
 }
 
-func benchmarkUnmarshalBigV2(b *testing.B) {
-	f, err := os.Open("large-file.json")
-	require.NoError(b, err)
-	defer f.Close()
+func benchmarkBigParserPitrFile(b *testing.B) {
+	emitter := func(k string, v any) bool {
+		return true
+	}
+	path := bigFile(b)
+	p := NewParserPitr(emitter)
+
+	for b.Loop() {
+		p.Reset(emitter)
+		err := p.ParseFile(path)
+		require.NoError(b, err)
+	}
+}
+
+func benchmarkBigParallelFile(b *testing.B) {
+	emitter := func(k string, v any) bool {
+		return true
+	}
+	path := bigFile(b)
+	p := NewParallel(emitter)
+
+	for b.Loop() {
+		p.Reset(emitter)
+		err := p.ParseFile(path)
+		require.NoError(b, err)
+	}
+}
+
+func benchmarkBigMemory(b *testing.B) {
+	f := bytes.NewReader(bigDoc())
 
 	for b.Loop() {
 		_, err := f.Seek(0, io.SeekStart)
 		require.NoError(b, err)
 
-		var m any
-		decoder := jsontext.NewDecoder(f)
-		err = jsonv2.UnmarshalDecode(decoder, &m)
+		p := new(Memory)
+		p.emitter = func(k string, v any) bool {
+			return true
+		}
+
+		err = p.Parse(f)
 		require.NoError(b, err)
 	}
 }
 
-func benchmarkUnmarshalBigSonic(b *testing.B) {
-	f, err := os.Open("large-file.json")
-	require.NoError(b, err)
-	defer f.Close()
+func benchmarkUnmarshalBig(b *testing.B) {
+	f := bytes.NewReader(bigDoc())
 
 	for b.Loop() {
 		_, err := f.Seek(0, io.SeekStart)
 		require.NoError(b, err)
 
 		var m any
-		decoder := sonic.ConfigDefault.NewDecoder(f)
+		decoder := json.NewDecoder(f)
 		err = decoder.Decode(&m)
 		require.NoError(b, err)
 	}
 }
+
+func benchmarkUnmarshalBigV2(b *testing.B) {
+	f := bytes.NewReader(bigDoc())
+
+	for b.Loop() {
+		_, err := f.Seek(0, io.SeekStart)
+		require.NoError(b, err)
+
+		var m any
+		decoder := jsontext.NewDecoder(f)
+		err = jsonv2.UnmarshalDecode(decoder, &m)
+		require.NoError(b, err)
+	}
+}
@@ -0,0 +1,453 @@
+// Command jsonflatten prints the flattened values of json documents.
+//
+// Usage:
+//
+//	jsonflatten [flags] [file ...]
+//
+// It reads the files in order, or the standard input when there are no
+// files or the file is "-". Each value is printed with its flattened key:
+//
+//	$ echo '{"a": {"b": [1, "x"]}}' | jsonflatten
+//	a.b.0 = 1
+//	a.b.1 = "x"
+//
+// The flags select the flattener, the key separator, the array mode, the
+// keys printed with -include and -exclude patterns, the number format and
+// the output format. Run jsonflatten -h to list them.
+//
+// With -format gron the documents are printed as gron statements, including
+// the containers and sorted like gron, and -ungron converts gron statements
+// back to json:
+//
+//	$ echo '{"b": [1, "x"], "a": {}}' | jsonflatten -format gron
+//	json = {};
+//	json.a = {};
+//	json.b = [];
+//	json.b[0] = 1;
+//	json.b[1] = "x";
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/jfontan/jsonflatten"
+)
+
+type flattener interface {
+	Parse(io.Reader) error
+	ParseFile(path string) error
+	Reset(jsonflatten.Emitter)
+}
+
+var parsers = map[string]func(jsonflatten.Emitter, ...jsonflatten.Option) flattener{
+	"v1": func(e jsonflatten.Emitter, o ...jsonflatten.Option) flattener {
+		return jsonflatten.NewParser(e, o...)
+	},
+	"v2": func(e jsonflatten.Emitter, o ...jsonflatten.Option) flattener {
+		return jsonflatten.NewParserV2(e, o...)
+	},
+	"pitr": func(e jsonflatten.Emitter, o ...jsonflatten.Option) flattener {
+		return jsonflatten.NewParserPitr(e, o...)
+	},
+	"fast": func(e jsonflatten.Emitter, o ...jsonflatten.Option) flattener {
+		return jsonflatten.NewParserFast(e, o...)
+	},
+	"memory": func(e jsonflatten.Emitter, o ...jsonflatten.Option) flattener {
+		return jsonflatten.NewMemory(e, o...)
+	},
+	"memoryv2": func(e jsonflatten.Emitter, o ...jsonflatten.Option) flattener {
+		return jsonflatten.NewMemoryV2(e, o...)
+	},
+	"parallel": func(e jsonflatten.Emitter, o ...jsonflatten.Option) flattener {
+		return jsonflatten.NewParallel(e, o...)
+	},
+}
+
+var arrayModes = map[string]jsonflatten.ArrayMode{
+	"index":    jsonflatten.ArrayIndex,
+	"raw":      jsonflatten.ArrayRaw,
+	"join":     jsonflatten.ArrayJoin,
+	"wildcard": jsonflatten.ArrayWildcard,
+	"drop":     jsonflatten.ArrayDrop,
+}
+
+// patterns is a flag that can be repeated.
+type patterns []string
+
+func (p *patterns) String() string {
+	return strings.Join(*p, ",")
+}
+
+func (p *patterns) Set(s string) error {
+	*p = append(*p, s)
+	return nil
+}
+
+// match reports whether key matches the pattern. "*" matches any sequence
+// of characters, including the separator, and "?" any single character.
+func match(pattern, key string) bool {
+	// position of the last star and of the key when it was found
+	star, next := -1, 0
+
+	p, k := 0, 0
+	for k < len(key) {
+		switch {
+		case p < len(pattern) && pattern[p] == '*':
+			star, next = p, k
+			p++
+		case p < len(pattern) && (pattern[p] == '?' || pattern[p] == key[k]):
+			p++
+			k++
+		case star >= 0:
+			// the star takes one more character
+			next++
+			p, k = star+1, next
+		default:
+			return false
+		}
+	}
+
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+
+	return p == len(pattern)
+}
+
+// config has the values of the flags.
+type config struct {
+	parser    string
+	separator string
+	arrays    string
+	include   patterns
+	exclude   patterns
+	numbers   string
+	format    string
+	lenient   bool
+	strict    bool
+	ungron    bool
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run executes the command and returns the exit code.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	var c config
+
+	flags := flag.NewFlagSet("jsonflatten", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: jsonflatten [flags] [file ...]")
+		flags.PrintDefaults()
+	}
+
+	flags.StringVar(&c.parser, "parser", "fast",
+		"flattener: v1, v2, pitr, fast, memory, memoryv2 or parallel")
+	flags.StringVar(&c.separator, "separator", ".", "separator of the key segments")
+	flags.StringVar(&c.arrays, "arrays", "index",
+		"array mode: index, raw, join, wildcard or drop")
+	flags.Var(&c.include, "include",
+		"only print keys that match the pattern, * matches any characters and ? one, can be repeated")
+	flags.Var(&c.exclude, "exclude",
+		"do not print keys that match the pattern, can be repeated")
+	flags.StringVar(&c.numbers, "numbers", "json",
+		"number format: json, decimal or string")
+	flags.StringVar(&c.format, "format", "text", "output format: text, json, csv, tsv, dotenv, properties or gron")
+	flags.BoolVar(&c.lenient, "lenient", false,
+		"accept comments, trailing commas and single quotes")
+	flags.BoolVar(&c.strict, "strict", false, "fail with data after the document")
+	flags.BoolVar(&c.ungron, "ungron", false,
+		"read gron statements and print the json document")
+
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+
+	out := bufio.NewWriter(stdout)
+
+	var err error
+	if c.ungron {
+		err = ungronFiles(flags, stdin, out)
+	} else {
+		var w writer
+		w, err = c.writer(flags, out)
+		if err == nil {
+			err = c.flatten(flags.Args(), stdin, w)
+		}
+	}
+	if ferr := out.Flush(); err == nil {
+		err = ferr
+	}
+
+	if err != nil {
+		fmt.Fprintln(stderr, "jsonflatten:", err)
+		return 1
+	}
+
+	return 0
+}
+
+// flatten writes the values of each file.
+func (c *config) flatten(files []string, stdin io.Reader, w writer) error {
+	f, ok := parsers[c.parser]
+	if !ok {
+		return fmt.Errorf("unknown parser %q", c.parser)
+	}
+
+	mode, ok := arrayModes[c.arrays]
+	if !ok {
+		return fmt.Errorf("unknown array mode %q", c.arrays)
+	}
+
+	var werr error
+	var num []byte
+	emitter := func(k string, v jsonflatten.Value) bool {
+		if !c.match(k) {
+			return true
+		}
+
+		if v.Kind == jsonflatten.KindNumber && c.numbers == "string" {
+			num = jsonflatten.AppendNumber(num[:0], v.Num)
+			v = jsonflatten.Value{
+				Kind: jsonflatten.KindString,
+				Str:  string(num),
+			}
+		}
+
+		werr = w.value(k, v)
+		return werr == nil
+	}
+
+	opts := []jsonflatten.Option{
+		jsonflatten.WithTypedEmitter(emitter),
+		jsonflatten.WithSeparator(c.separator),
+		jsonflatten.WithArrayMode(mode),
+		jsonflatten.WithLenient(c.lenient),
+		jsonflatten.WithStrict(c.strict),
+	}
+	if g, ok := w.(*gronWriter); ok {
+		opts = append(opts, jsonflatten.WithGron(g.g))
+	}
+
+	p := f(nil, opts...)
+
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+
+	for _, file := range files {
+		if err := w.begin(); err != nil {
+			return err
+		}
+
+		// the values are sent to the typed emitter
+		p.Reset(nil)
+
+		var err error
+		if file == "-" {
+			err = p.Parse(stdin)
+		} else {
+			err = p.ParseFile(file)
+		}
+
+		if werr != nil {
+			return werr
+		}
+		if err != nil {
+			// the gron statements parsed before the error are printed
+			// like the values of the other formats
+			if g, ok := w.(*gronWriter); ok {
+				g.end()
+			}
+			return fmt.Errorf("%s: %w", file, err)
+		}
+
+		if err := w.end(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ungronFiles writes the gron statements of the files as json documents.
+// The flags of the flatteners can not be used.
+func ungronFiles(flags *flag.FlagSet, stdin io.Reader, out io.Writer) error {
+	if err := onlyFlags(flags, "-ungron", "ungron"); err != nil {
+		return err
+	}
+
+	files := flags.Args()
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+
+	for _, file := range files {
+		if err := ungronFile(file, stdin, out); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ungronFile converts the gron statements of file, stdin for "-".
+func ungronFile(file string, stdin io.Reader, out io.Writer) error {
+	r := stdin
+	if file != "-" {
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	if err := jsonflatten.Ungron(r, out); err != nil {
+		return fmt.Errorf("%s: %w", file, err)
+	}
+
+	return nil
+}
+
+// onlyFlags fails if a flag that is not in allowed was set, as it is not
+// used by the mode.
+func onlyFlags(flags *flag.FlagSet, mode string, allowed ...string) error {
+	var err error
+	flags.Visit(func(f *flag.Flag) {
+		if err == nil && !slices.Contains(allowed, f.Name) {
+			err = fmt.Errorf("flag -%s can not be used with %s", f.Name, mode)
+		}
+	})
+
+	return err
+}
+
+// match returns true when the key is printed.
+func (c *config) match(key string) bool {
+	for _, p := range c.exclude {
+		if match(p, key) {
+			return false
+		}
+	}
+
+	if len(c.include) == 0 {
+		return true
+	}
+
+	for _, p := range c.include {
+		if match(p, key) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// writer prints the values of the documents.
+type writer interface {
+	// begin is called before each file.
+	begin() error
+	value(key string, v jsonflatten.Value) error
+	// end is called after each file.
+	end() error
+}
+
+var formats = map[string]jsonflatten.Format{
+	"text":       jsonflatten.FormatText,
+	"json":       jsonflatten.FormatJSON,
+	"csv":        jsonflatten.FormatCSV,
+	"tsv":        jsonflatten.FormatTSV,
+	"dotenv":     jsonflatten.FormatDotenv,
+	"properties": jsonflatten.FormatProperties,
+}
+
+func (c *config) writer(flags *flag.FlagSet, w *bufio.Writer) (writer, error) {
+	if c.format == "gron" {
+		// the keys and values are written by Gron
+		err := onlyFlags(flags, "-format gron",
+			"format", "parser", "lenient", "strict")
+		if err != nil {
+			return nil, err
+		}
+		return &gronWriter{g: jsonflatten.NewGron(w)}, nil
+	}
+
+	// the writer formats numbers like encoding/json by default, numbers
+	// printed as strings are converted by the emitter
+	number := jsonflatten.AppendNumber
+	switch c.numbers {
+	case "json", "string":
+	case "decimal":
+		number = func(b []byte, f float64) []byte {
+			return strconv.AppendFloat(b, f, 'f', -1, 64)
+		}
+	default:
+		return nil, fmt.Errorf("unknown number format %q", c.numbers)
+	}
+
+	format, ok := formats[c.format]
+	if !ok {
+		return nil, fmt.Errorf("unknown output format %q", c.format)
+	}
+
+	return &formatWriter{out: w, format: format, number: number}, nil
+}
+
+// formatWriter prints the values of each file with a jsonflatten.Writer.
+type formatWriter struct {
+	out    io.Writer
+	format jsonflatten.Format
+	number func([]byte, float64) []byte
+	w      *jsonflatten.Writer
+}
+
+func (f *formatWriter) begin() error {
+	f.w = jsonflatten.NewWriter(f.out, f.format)
+	f.w.Number = f.number
+	return nil
+}
+
+func (f *formatWriter) value(key string, v jsonflatten.Value) error {
+	if f.w.EmitTyped(key, v) {
+		return nil
+	}
+
+	// the writer only stops on write errors
+	return f.w.Close()
+}
+
+func (f *formatWriter) end() error {
+	return f.w.Close()
+}
+
+// gronWriter prints the documents as gron statements. The flattener sends
+// the values to Gron with WithGron instead of calling value.
+type gronWriter struct {
+	g *jsonflatten.Gron
+}
+
+func (g *gronWriter) begin() error {
+	return nil
+}
+
+func (g *gronWriter) value(string, jsonflatten.Value) error {
+	return nil
+}
+
+func (g *gronWriter) end() error {
+	return g.g.Flush()
+}
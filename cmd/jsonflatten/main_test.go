@@ -0,0 +1,234 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const doc = `{"a": {"b": [1, "x\n\"y\""]}, "c": null, "d": 1e21, "e": true}`
+
+func execute(t *testing.T, stdin string, args ...string) (string, string, int) {
+	t.Helper()
+
+	var stdout, stderr bytes.Buffer
+	code := run(args, strings.NewReader(stdin), &stdout, &stderr)
+
+	return stdout.String(), stderr.String(), code
+}
+
+func TestRun(t *testing.T) {
+	expected := `a.b.0 = 1
+a.b.1 = "x\n\"y\""
+c = null
+d = 1e+21
+e = true
+`
+
+	for name := range parsers {
+		t.Run(name, func(t *testing.T) {
+			out, _, code := execute(t, doc, "-parser", name)
+			require.Equal(t, 0, code)
+			require.Equal(t, expected, out)
+		})
+	}
+}
+
+func TestRunFlags(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		expected string
+	}{
+		{
+			name:     "separator",
+			args:     []string{"-separator", "/", "-include", "a/*"},
+			expected: "a/b/0 = 1\na/b/1 = \"x\\n\\\"y\\\"\"\n",
+		},
+		{
+			name:     "include",
+			args:     []string{"-include", "c", "-include", "e"},
+			expected: "c = null\ne = true\n",
+		},
+		{
+			name:     "exclude",
+			args:     []string{"-exclude", "a.*", "-exclude", "c"},
+			expected: "d = 1e+21\ne = true\n",
+		},
+		{
+			name:     "decimal numbers",
+			args:     []string{"-numbers", "decimal", "-include", "d"},
+			expected: "d = 1000000000000000000000\n",
+		},
+		{
+			name:     "string numbers",
+			args:     []string{"-numbers", "string", "-include", "a.b.0"},
+			expected: "a.b.0 = \"1\"\n",
+		},
+		{
+			name:     "arrays",
+			args:     []string{"-arrays", "join", "-include", "a.*"},
+			expected: "a.b = \"1,x\\n\\\"y\\\"\"\n",
+		},
+		{
+			name: "json",
+			args: []string{"-format", "json", "-exclude", "a.*"},
+			expected: `{
+  "c": null,
+  "d": 1e+21,
+  "e": true
+}
+`,
+		},
+		{
+			name:     "csv",
+			args:     []string{"-format", "csv", "-numbers", "decimal"},
+			expected: "key,value\na.b.0,1\na.b.1,\"x\n\"\"y\"\"\"\nc,null\nd,1000000000000000000000\ne,true\n",
+		},
+		{
+			name:     "tsv",
+			args:     []string{"-format", "tsv", "-include", "a.*"},
+			expected: "key\tvalue\na.b.0\t1\na.b.1\tx\\n\"y\"\n",
+		},
+		{
+			name:     "dotenv",
+			args:     []string{"-format", "dotenv", "-numbers", "string"},
+			expected: "A_B_0=\"1\"\nA_B_1=\"x\\n\\\"y\\\"\"\nC=\nD=\"1e+21\"\nE=true\n",
+		},
+		{
+			name:     "properties",
+			args:     []string{"-format", "properties", "-exclude", "a.*"},
+			expected: "c=\nd=1e+21\ne=true\n",
+		},
+		{
+			name:     "empty json",
+			args:     []string{"-format", "json", "-include", "none"},
+			expected: "{}\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			out, stderr, code := execute(t, doc, test.args...)
+			require.Equal(t, 0, code, stderr)
+			require.Equal(t, test.expected, out)
+		})
+	}
+}
+
+func TestRunFiles(t *testing.T) {
+	dir := t.TempDir()
+	first := filepath.Join(dir, "first.json")
+	second := filepath.Join(dir, "second.json")
+	require.NoError(t, os.WriteFile(first, []byte(`{"a": 1}`), 0o644))
+	require.NoError(t, os.WriteFile(second, []byte(`[2]`), 0o644))
+
+	out, _, code := execute(t, `{"b": 3}`, first, "-", second)
+	require.Equal(t, 0, code)
+	require.Equal(t, "a = 1\nb = 3\n0 = 2\n", out)
+
+	_, stderr, code := execute(t, "", filepath.Join(dir, "missing.json"))
+	require.Equal(t, 1, code)
+	require.Contains(t, stderr, "missing.json")
+}
+
+func TestRunGron(t *testing.T) {
+	expected := `json = {};
+json.a = {};
+json.a.b = [];
+json.a.b[0] = 1;
+json.a.b[1] = "x\n\"y\"";
+json.c = null;
+json.d = 1e+21;
+json.e = true;
+`
+
+	out, stderr, code := execute(t, doc, "-format", "gron")
+	require.Equal(t, 0, code, stderr)
+	require.Equal(t, expected, out)
+
+	// the flattener flags are used
+	for parser := range parsers {
+		out, stderr, code := execute(t, "{'b': [1,], 'a': 2}", "-format", "gron",
+			"-parser", parser, "-lenient")
+		require.Equal(t, 0, code, stderr)
+		require.Equal(t, "json = {};\njson.a = 2;\njson.b = [];\njson.b[0] = 1;\n", out)
+	}
+
+	partial, stderr, code := execute(t, `{"b": 1, "a": [2`, "-format", "gron")
+	require.Equal(t, 1, code)
+	require.Equal(t, "json = {};\njson.a = [];\njson.a[0] = 2;\njson.b = 1;\n", partial)
+	require.Contains(t, stderr, "jsonflatten: -: truncated")
+
+	_, stderr, code = execute(t, doc, "-format", "gron", "-separator", "/")
+	require.Equal(t, 1, code)
+	require.Contains(t, stderr, "flag -separator can not be used with -format gron")
+
+	_, stderr, code = execute(t, doc, "-ungron", "-lenient")
+	require.Equal(t, 1, code)
+	require.Contains(t, stderr, "flag -lenient can not be used with -ungron")
+
+	path := filepath.Join(t.TempDir(), "doc.gron")
+	require.NoError(t, os.WriteFile(path, []byte(out), 0o644))
+
+	out, stderr, code = execute(t, "", "-ungron", path)
+	require.Equal(t, 0, code, stderr)
+	require.JSONEq(t, doc, out)
+
+	_, stderr, code = execute(t, "json.a = x;", "-ungron")
+	require.Equal(t, 1, code)
+	require.Contains(t, stderr, "jsonflatten: -: syntax error: line 1")
+}
+
+func TestRunErrors(t *testing.T) {
+	tests := [][]string{
+		{"-parser", "unknown"},
+		{"-arrays", "unknown"},
+		{"-numbers", "unknown"},
+		{"-format", "unknown"},
+	}
+
+	for _, args := range tests {
+		_, stderr, code := execute(t, doc, args...)
+		require.Equal(t, 1, code)
+		require.Contains(t, stderr, "unknown")
+	}
+
+	out, stderr, code := execute(t, `{"a": 1} x`)
+	require.Equal(t, 1, code)
+	require.Equal(t, "a = 1\n", out)
+	require.Contains(t, stderr, "jsonflatten: -:")
+
+	_, _, code = execute(t, doc, "-unknown")
+	require.Equal(t, 2, code)
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern, key string
+		expected     bool
+	}{
+		{"a.b", "a.b", true},
+		{"a.b", "a.bc", false},
+		{"a.*", "a.b.c", true},
+		{"a.*", "a", false},
+		{"*.id", "items.0.id", true},
+		{"*.id", "items.0.idx", false},
+		{"a.?.c", "a.0.c", true},
+		{"a.?.c", "a.10.c", false},
+		{"*a*b*", "xxaxxbxx", true},
+		{"*a*b", "xxaxxbxxc", false},
+		{"*", "", true},
+		{"", "", true},
+		{"", "a", false},
+	}
+
+	for _, test := range tests {
+		require.Equal(t, test.expected, match(test.pattern, test.key),
+			"%q %q", test.pattern, test.key)
+	}
+}
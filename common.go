@@ -3,36 +3,318 @@ package jsonflatten
 import (
 	"errors"
 	"fmt"
-	"strings"
+	"io"
+	"os"
 )
 
-var errExit = errors.New("exit")
+var (
+	errExit       = errors.New("exit")
+	errMissingKey = fmt.Errorf("%w: object value without key", ErrSyntax)
+)
 
 type commonParser struct {
 	States
 
 	emitter Emitter
+	options options
+	capture *capture
+
+	keyCache map[string]string
+	keys     internTable
+	values   int
+	objects  int
+	pending  []keyValue
+	done     bool
+	path     pathBuffer
+
+	// offset is the index of the first element of the root array, used
+	// by Parallel to flatten a part of a bigger array
+	offset int
+
+	limit   limitReader
+	lenient *lenientReader
+	utf8    *utf8Reader
+
+	// text writes the values when there is no emitter
+	text *Writer
 }
 
-func newCommonParser(emitter Emitter) commonParser {
-	c := commonParser{
+func newCommonParser(emitter Emitter, opts []Option) commonParser {
+	return commonParser{
 		emitter: emitter,
+		options: newOptions(opts),
+	}
+}
+
+// input wraps the reader with the limit and lenient readers. The readers
+// are kept to be reused by the next Parse call.
+func (p *commonParser) input(r io.Reader) io.Reader {
+	r = p.limitInput(r)
+	if p.options.lenient {
+		if p.lenient == nil {
+			p.lenient = newLenientReader(r)
+		} else {
+			p.lenient.reset(r)
+		}
+		r = p.lenient
+	}
+
+	return r
+}
+
+// decoderInput wraps the reader like input for the encoding/json decoder.
+// The decoder replaces invalid UTF-8 and lone surrogates, in UTF8Reject
+// mode the input is checked before by utf8Reader and UTF8PassThrough is not
+// supported.
+func (p *commonParser) decoderInput(r io.Reader) (io.Reader, error) {
+	r = p.input(r)
+
+	switch p.options.utf8 {
+	case UTF8PassThrough:
+		return nil, errPassThrough
+	case UTF8Reject:
+		if p.utf8 == nil {
+			p.utf8 = newUTF8Reader(r)
+		} else {
+			p.utf8.reset(r)
+		}
+		return p.utf8, nil
+	default:
+		return r, nil
+	}
+}
+
+// Reset changes the emitter so the parser can be reused with a different
+// one. The state of the previous document is cleared by each Parse call and
+// internal buffers are kept to reduce allocations,
+// for example to keep parsers in a sync.Pool:
+//
+//	var pool = sync.Pool{
+//		New: func() any { return jsonflatten.NewParserPitr(nil) },
+//	}
+//
+//	p := pool.Get().(*jsonflatten.ParserPitr)
+//	p.Reset(emitter)
+//	err := p.Parse(r)
+//	pool.Put(p)
+func (p *commonParser) Reset(emitter Emitter) {
+	p.emitter = emitter
+	p.offset = 0
+	p.begin()
+}
+
+// begin clears the state left by the previous document, also when it failed
+// or was stopped by the emitter, so it does not leak into the next one.
+func (p *commonParser) begin() {
+	p.States.reset()
+	p.capture = nil
+	p.values = 0
+	p.objects = 0
+	p.pending = p.pending[:0]
+	p.done = false
+	p.path = p.path[:0]
+}
+
+// openContainer is called when an object or array starts.
+func (p *commonParser) openContainer(t Type) error {
+	if err := p.checkTrailing(); err != nil {
+		return err
+	}
+
+	if err := p.checkDepth(); err != nil {
+		return err
+	}
+
+	if s := p.lastState(); s.jsonType == TypeObject && !s.hasKey {
+		return errMissingKey
+	}
+
+	if t == TypeObject {
+		p.objects++
+	}
+
+	if p.capture != nil {
+		s := p.lastState()
+		p.capture.open(s, p.path.name(s), t, p.captureTop())
+		p.pushState(t, p.path.extend(s, p.options.keySeparator()))
+		return nil
+	}
+
+	if p.lastState().skip {
+		p.capture = newCapture(ArrayDrop, "", len(p.States))
+	} else if t == TypeArray {
+		switch p.options.arrayMode {
+		case ArrayDrop:
+			p.capture = newCapture(ArrayDrop, "", len(p.States))
+		case ArrayRaw, ArrayJoin:
+			if len(p.States) > 0 {
+				p.capture = newCapture(
+					p.options.arrayMode,
+					p.options.arraySeparator,
+					len(p.States),
+				)
+			}
+		}
+	}
+
+	if p.options.gron != nil && p.capture == nil {
+		if !p.gronContainer(t) {
+			return errExit
+		}
 	}
 
-	if emitter == nil {
-		c.emitter = c.print
+	p.pushState(t, p.path.extend(p.lastState(), p.options.keySeparator()))
+	p.lastState().gron = p.options.gron != nil
+
+	if t == TypeArray && len(p.States) == 1 {
+		p.lastState().arrayCounter = p.offset
+	}
+
+	if t == TypeArray && p.capture == nil &&
+		p.options.arrayMode == ArrayWildcard {
+		p.lastState().wildcard = true
 	}
 
-	return c
+	return nil
 }
 
-func (p *commonParser) commonEmitter(v any) error {
+// closeContainer is called when an object or array ends.
+func (p *commonParser) closeContainer(t Type) error {
+	s := p.popState()
+	if s.jsonType != t {
+		return fmt.Errorf("%w: invalid end of %s", ErrSyntax, t)
+	}
+
+	if t == TypeObject {
+		p.objects--
+		p.pending = s.dropDuplicates(p.pending)
+	}
+
+	if p.capture != nil {
+		if len(p.States) > p.capture.level {
+			p.capture.close(t)
+			p.lastState().advance()
+			return nil
+		}
+
+		c := p.capture
+		p.capture = nil
+
+		v, ok := c.finish()
+		if ok && len(p.States) > 0 {
+			if !p.emit(p.lastState(), stringValue(v)) {
+				return errExit
+			}
+		}
+	}
+
+	if len(p.States) == 0 {
+		p.done = true
+	}
+
+	if p.objects == 0 && len(p.pending) > 0 {
+		if !p.flushPending() {
+			return errExit
+		}
+	}
+
+	if len(p.States) == 0 && p.options.gron != nil {
+		if !p.options.gron.end() {
+			return errExit
+		}
+	}
+
+	p.lastState().advance()
+
+	return nil
+}
+
+// stringToken is called for each string found, both keys and values.
+func (p *commonParser) stringToken(v string) error {
 	s := p.lastState()
-	if s == nil {
-		return fmt.Errorf("single value not supported")
+
+	switch s.jsonType {
+	case TypeObject:
+		if !s.hasKey {
+			if err := p.checkKey(v); err != nil {
+				return err
+			}
+			if p.capture == nil {
+				v = p.transformKey(v)
+				if err := p.duplicateKey(s, v); err != nil {
+					return err
+				}
+			}
+			p.path.setKey(s, v)
+			s.hasKey = true
+			return nil
+		}
+
+		if err := p.checkString(v); err != nil {
+			return err
+		}
+		return p.commonEmitter(stringValue(v))
+
+	case TypeArray:
+		if err := p.checkString(v); err != nil {
+			return err
+		}
+		return p.commonEmitter(stringValue(v))
+
+	default:
+		if err := p.checkTrailing(); err != nil {
+			return err
+		}
+		return fmt.Errorf("%w: single strings", errors.ErrUnsupported)
 	}
+}
 
-	ok := p.emit(s.key, v)
+// keyToken handles an object key that may share memory with the tokenizer
+// buffer. When the key is not transformed or checked for duplicates it is
+// copied directly to the path buffer, otherwise it is interned.
+func (p *commonParser) keyToken(k string) error {
+	if p.options.keyTransform != nil || p.options.duplicates != DuplicateAll {
+		return p.stringToken(p.keys.intern(k))
+	}
+
+	if err := p.checkKey(k); err != nil {
+		return err
+	}
+
+	s := p.lastState()
+	p.path.setKey(s, k)
+	s.hasKey = true
+
+	return nil
+}
+
+func (p *commonParser) commonEmitter(v Value) error {
+	if len(p.States) == 0 {
+		if err := p.checkTrailing(); err != nil {
+			return err
+		}
+		return fmt.Errorf("%w: single value", errors.ErrUnsupported)
+	}
+	if err := p.checkValues(); err != nil {
+		return err
+	}
+	s := p.lastState()
+	if s.jsonType == TypeObject && !s.hasKey {
+		return errMissingKey
+	}
+
+	if p.capture != nil {
+		p.capture.value(s, p.path.name(s), v, p.captureTop())
+		s.advance()
+		return nil
+	}
+
+	if s.skip {
+		s.advance()
+		return nil
+	}
+
+	ok := p.emit(s, v)
 	if !ok {
 		return errExit
 	}
@@ -42,37 +324,49 @@ func (p *commonParser) commonEmitter(v any) error {
 	return nil
 }
 
-func (p *commonParser) emit(k string, v any) bool {
-	var path path
-	s := p.lastState()
-	if s != nil {
-		path = s.path
+// captureTop returns true when the current container is the captured array.
+func (p *commonParser) captureTop() bool {
+	return len(p.States)-1 == p.capture.level
+}
+
+// emit calls the emitter with the current key of s.
+func (p *commonParser) emit(s *State, v Value) bool {
+	key := p.path.key(s)
+	if p.objects > 0 && p.options.duplicates == DuplicateLast {
+		p.pending = append(p.pending, keyValue{
+			key:   append([]byte(nil), key...),
+			value: v.clone(),
+		})
+		return true
 	}
 
-	// spew.Print(path)
-	return p.emitter(path.StringWithKey(k), v)
+	return p.call(key, v)
 }
 
-func (p *commonParser) print(k string, v any) bool {
-	var value string
-	switch nv := v.(type) {
-	case string:
-		value = fmt.Sprintf(`"%s"`, nv)
-	default:
-		value = fmt.Sprintf("%v", nv)
+// printer returns the text emitter that writes the values when there is no
+// emitter.
+func (p *commonParser) printer() *Writer {
+	if p.text == nil {
+		out := p.options.output
+		if out == nil {
+			out = os.Stdout
+		}
+		p.text = NewTextEmitter(out)
 	}
 
-	fmt.Println(k, "=", value)
-
-	return true
+	return p.text
 }
 
-type path []string
+// flush writes the values buffered by the printer when the parse finishes. It
+// returns err or the write error.
+func (p *commonParser) flush(err error) error {
+	if p.text == nil {
+		return err
+	}
 
-func (p path) StringWithKey(k string) string {
-	return strings.Join(append(p, k), ".")
-}
+	if ferr := p.text.Flush(); err == nil {
+		err = ferr
+	}
 
-func (p path) String() string {
-	return strings.Join(p, ".")
+	return err
 }
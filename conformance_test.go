@@ -0,0 +1,258 @@
+package jsonflatten
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// conformance is a test document. The names follow JSONTestSuite: y_ must
+// be accepted, n_ must fail and i_ depends on the implementation, for them
+// the case sets what jsonflatten does. f_ are flattening cases.
+type conformance struct {
+	name string
+	doc  string
+	// expected pairs for valid documents, sorted by key
+	expected []pair
+	// class of the error, see errorClass
+	class string
+}
+
+// deep returns a document with n nested objects.
+func deep(n int) (string, string) {
+	doc := strings.Repeat(`{"a":`, n) + "1" + strings.Repeat("}", n)
+	key := strings.TrimSuffix(strings.Repeat("a.", n), ".")
+	return doc, key
+}
+
+var conformanceDocs = func() []conformance {
+	deepDoc, deepKey := deep(200)
+
+	return []conformance{
+		// accepted
+		{name: "y_array_empty", doc: `[]`},
+		{name: "y_object_empty", doc: `{}`},
+		{name: "y_array_arraysWithSpaces", doc: `[[]   ]`},
+		{
+			name: "y_array_heterogeneous",
+			doc:  `[null, 1, "1", {}]`,
+			expected: []pair{
+				{"0", nil},
+				{"1", float64(1)},
+				{"2", "1"},
+			},
+		},
+		{
+			name:     "y_array_with_leading_space",
+			doc:      ` [1]`,
+			expected: []pair{{"0", float64(1)}},
+		},
+		{
+			name: "y_structure_whitespace",
+			doc:  "\n[ 1 ,\t2 ]\r\n",
+			expected: []pair{
+				{"0", float64(1)},
+				{"1", float64(2)},
+			},
+		},
+		{
+			name: "y_structure_lonely_literals",
+			doc:  `[true, false, null]`,
+			expected: []pair{
+				{"0", true},
+				{"1", false},
+				{"2", nil},
+			},
+		},
+		{
+			name: "y_number",
+			doc:  `[123e65, -0, 1E22, 1e-2, -1.5e+3, 0.0, 20e1, 1E+2]`,
+			expected: []pair{
+				{"0", 123e65},
+				{"1", float64(0)},
+				{"2", 1e22},
+				{"3", 1e-2},
+				{"4", -1.5e3},
+				{"5", float64(0)},
+				{"6", float64(200)},
+				{"7", float64(100)},
+			},
+		},
+		{
+			name:     "y_number_very_big_negative_int",
+			doc:      `[-237462374673276894279832749832423479823246327846]`,
+			expected: []pair{{"0", -237462374673276894279832749832423479823246327846.0}},
+		},
+		{
+			name:     "y_object_duplicated_key",
+			doc:      `{"a":"b","a":"c"}`,
+			expected: []pair{{"a", "b"}, {"a", "c"}},
+		},
+		{
+			name:     "y_object_escaped_null_in_key",
+			doc:      `{"foo\u0000bar": 42}`,
+			expected: []pair{{"foo\x00bar", float64(42)}},
+		},
+		{
+			name:     "y_string_escapes",
+			doc:      `["\"\\\/\b\f\n\r\t"]`,
+			expected: []pair{{"0", "\"\\/\b\f\n\r\t"}},
+		},
+		{
+			name:     "y_string_unicode_escapes",
+			doc:      `["\u00e9\u4e2d\u0041"]`,
+			expected: []pair{{"0", "\u00e9\u4e2dA"}},
+		},
+		{
+			name:     "y_string_surrogates_pair",
+			doc:      `["\ud83d\ude00"]`,
+			expected: []pair{{"0", "\U0001f600"}},
+		},
+		{
+			name:     "y_string_utf8",
+			doc:      "[\"\u20ac\U0001d11e\"]",
+			expected: []pair{{"0", "\u20ac\U0001d11e"}},
+		},
+		{
+			name:     "y_string_empty",
+			doc:      `[""]`,
+			expected: []pair{{"0", ""}},
+		},
+
+		// rejected
+		{name: "n_array_extra_comma", doc: `[1,]`, class: "syntax"},
+		{name: "n_array_missing_comma", doc: `[1 2]`, class: "syntax"},
+		{name: "n_array_leading_comma", doc: `[,1]`, class: "syntax"},
+		{name: "n_array_double_comma", doc: `[1,,2]`, class: "syntax"},
+		{name: "n_array_colon_instead_of_comma", doc: `["a": 1]`, class: "syntax"},
+		{name: "n_array_unclosed", doc: `[1`, class: "truncated"},
+		{name: "n_array_unclosed_trailing_comma", doc: `[1,`, class: "truncated"},
+		{name: "n_object_missing_colon", doc: `{"a" 1}`, class: "syntax"},
+		{name: "n_object_comma_instead_of_colon", doc: `{"a", 1}`, class: "syntax"},
+		{name: "n_object_double_colon", doc: `{"a"::1}`, class: "syntax"},
+		{name: "n_object_trailing_comma", doc: `{"a":1,}`, class: "syntax"},
+		{name: "n_object_missing_value", doc: `{"a":}`, class: "syntax"},
+		{name: "n_object_numeric_key", doc: `{1:1}`, class: "syntax"},
+		{name: "n_object_key_without_value", doc: `{"a"}`, class: "syntax"},
+		{name: "n_object_unclosed", doc: `{"a":1`, class: "truncated"},
+		{name: "n_object_unclosed_key", doc: `{"a":`, class: "truncated"},
+		{name: "n_structure_close_unopened_array", doc: `]`, class: "syntax"},
+		{name: "n_structure_object_with_array_end", doc: `{"a":1]`, class: "syntax"},
+		{name: "n_structure_array_with_object_end", doc: `[1}`, class: "syntax"},
+		{name: "n_structure_trailing_garbage", doc: `{"a":1} x`, class: "syntax"},
+		{name: "n_number_leading_zero", doc: `[01]`, class: "syntax"},
+		{name: "n_number_trailing_dot", doc: `[1.]`, class: "syntax"},
+		{name: "n_number_leading_dot", doc: `[.5]`, class: "syntax"},
+		{name: "n_number_minus", doc: `[-]`, class: "syntax"},
+		{name: "n_number_empty_exponent", doc: `[1e]`, class: "syntax"},
+		{name: "n_number_plus", doc: `[+1]`, class: "syntax"},
+		{name: "n_number_hex", doc: `[0x1]`, class: "syntax"},
+		{name: "n_number_infinity", doc: `[Infinity]`, class: "syntax"},
+		{name: "n_number_nan", doc: `[NaN]`, class: "syntax"},
+		{name: "n_literal_truncated_true", doc: `[tru]`, class: "syntax"},
+		{name: "n_literal_capitalized", doc: `[True]`, class: "syntax"},
+		{name: "n_string_single_quote", doc: `['a']`, class: "syntax"},
+		{name: "n_string_unclosed", doc: `["a`, class: "truncated"},
+		{name: "n_structure_scalar_root", doc: `"a"`, class: "unsupported"},
+
+		// implementation defined
+		{name: "i_number_huge_exp", doc: `[1e400]`, class: "syntax"},
+		{
+			name:     "i_string_lone_surrogate",
+			doc:      `["\ud800"]`,
+			expected: []pair{{"0", "\ufffd"}},
+		},
+		{
+			name:     "i_string_invalid_utf8",
+			doc:      "[\"a\xffb\"]",
+			expected: []pair{{"0", "a\ufffdb"}},
+		},
+		{name: "i_structure_UTF-8_BOM", doc: "\ufeff[]", class: "syntax"},
+		{name: "i_structure_empty", doc: ""},
+
+		// flattening
+		{
+			name:     "f_empty_keys",
+			doc:      `{"": {"": 1}, "a": {"": [2]}}`,
+			expected: []pair{{".", float64(1)}, {"a..0", float64(2)}},
+		},
+		{
+			name: "f_dotted_keys",
+			doc:  `{"a.b": {"c": 1}, "a": {"b.c": 2}}`,
+			expected: []pair{
+				{"a.b.c", float64(1)},
+				{"a.b.c", float64(2)},
+			},
+		},
+		{
+			name:     "f_deep_objects",
+			doc:      deepDoc,
+			expected: []pair{{deepKey, float64(1)}},
+		},
+		{
+			name:     "f_deep_arrays",
+			doc:      strings.Repeat("[", 100) + "1" + strings.Repeat("]", 100),
+			expected: []pair{{strings.TrimSuffix(strings.Repeat("0.", 100), "."), float64(1)}},
+		},
+		{
+			name:     "f_unicode_escaped_key",
+			doc:      `{"\u00e9\n": {"\ud83d\ude00": true}}`,
+			expected: []pair{{"\u00e9\n.\U0001f600", true}},
+		},
+		{
+			name:     "f_empty_containers",
+			doc:      `{"a": [], "b": {}, "c": [[], {}]}`,
+			expected: nil,
+		},
+		{
+			name: "f_concatenated",
+			doc:  `{"a": 1}[2]` + "\n" + `{"a": 3}`,
+			expected: []pair{
+				{"0", float64(2)},
+				{"a", float64(1)},
+				{"a", float64(3)},
+			},
+		},
+	}
+}()
+
+// errorClass groups the errors of the flatteners, the tokenizers return
+// different errors for invalid documents. Errors that are not in a class
+// fail the test.
+func errorClass(t *testing.T, err error) string {
+	t.Helper()
+
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, ErrTruncated):
+		return "truncated"
+	case errors.Is(err, ErrTrailingData):
+		return "trailing"
+	case errors.Is(err, ErrInvalidUTF8):
+		return "utf8"
+	case errors.Is(err, ErrSyntax):
+		return "syntax"
+	case errors.Is(err, errors.ErrUnsupported):
+		return "unsupported"
+	default:
+		t.Fatalf("error without class: %v", err)
+		return ""
+	}
+}
+
+func TestConformance(t *testing.T) {
+	for _, test := range conformanceDocs {
+		for name, f := range flatteners {
+			t.Run(test.name+"/"+name, func(t *testing.T) {
+				pairs, err := collect(t, f, test.doc)
+				require.Equal(t, test.class, errorClass(t, err), "error: %v", err)
+				if test.class == "" {
+					require.Equal(t, test.expected, pairs)
+				}
+			})
+		}
+	}
+}
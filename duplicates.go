@@ -0,0 +1,123 @@
+package jsonflatten
+
+import (
+	"errors"
+	"fmt"
+)
+
+// DuplicateMode selects what to do with repeated keys in the same object.
+type DuplicateMode int
+
+const (
+	// DuplicateAll emits the values of all the repeated keys. This is the
+	// default.
+	DuplicateAll DuplicateMode = iota
+	// DuplicateError stops parsing with ErrDuplicateKey.
+	DuplicateError
+	// DuplicateFirst only emits the value of the first key.
+	DuplicateFirst
+	// DuplicateLast only emits the value of the last key. Values are kept in
+	// memory until the outermost object ends as a later key can replace
+	// them.
+	DuplicateLast
+)
+
+// ErrDuplicateKey is returned when an object has a repeated key and the
+// duplicate mode is DuplicateError.
+var ErrDuplicateKey = errors.New("duplicate key")
+
+// WithDuplicateKeys sets what to do with repeated keys in the same object.
+// Keys are compared after the key transform is applied.
+func WithDuplicateKeys(mode DuplicateMode) Option {
+	return func(o *options) {
+		o.duplicates = mode
+	}
+}
+
+// span is the position in the pending values where the values of an object
+// member start.
+type span struct {
+	key   string
+	start int
+}
+
+type keyValue struct {
+	key   []byte
+	value Value
+}
+
+// duplicateKey tracks the keys seen in the object and applies the duplicate
+// mode to the new key.
+func (p *commonParser) duplicateKey(s *State, k string) error {
+	mode := p.options.duplicates
+	if mode == DuplicateAll {
+		return nil
+	}
+
+	if s.seen == nil {
+		s.seen = make(map[string]int)
+	}
+	_, dup := s.seen[k]
+
+	switch mode {
+	case DuplicateError:
+		if dup {
+			return fmt.Errorf("%w: %s", ErrDuplicateKey, p.path.withKey(s, k))
+		}
+		s.seen[k] = 0
+
+	case DuplicateFirst:
+		if dup {
+			s.skip = true
+		}
+		s.seen[k] = 0
+
+	case DuplicateLast:
+		if dup {
+			s.duplicated = true
+		}
+		s.seen[k] = len(s.spans)
+		s.spans = append(s.spans, span{key: k, start: len(p.pending)})
+	}
+
+	return nil
+}
+
+// dropDuplicates removes from pending the values of the members replaced by
+// a later key in the same object.
+func (s *State) dropDuplicates(pending []keyValue) []keyValue {
+	if !s.duplicated {
+		return pending
+	}
+
+	out := s.spans[0].start
+	for i, m := range s.spans {
+		end := len(pending)
+		if i+1 < len(s.spans) {
+			end = s.spans[i+1].start
+		}
+
+		if s.seen[m.key] != i {
+			continue
+		}
+
+		out += copy(pending[out:], pending[m.start:end])
+	}
+
+	return pending[:out]
+}
+
+// flushPending emits the values kept with DuplicateLast.
+func (p *commonParser) flushPending() bool {
+	defer func() {
+		p.pending = p.pending[:0]
+	}()
+
+	for _, kv := range p.pending {
+		if !p.call(kv.key, kv.value) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,97 @@
+package jsonflatten
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const duplicatesJson = `{
+	"a": 1,
+	"b": {"c": 1, "d": 2},
+	"a": 2,
+	"e": [{"f": 1, "f": 2}],
+	"b": {"c": 3},
+	"a": 3
+}`
+
+func TestDuplicateKeys(t *testing.T) {
+	tests := []struct {
+		name     string
+		mode     DuplicateMode
+		expected []pair
+	}{
+		{
+			name: "all",
+			mode: DuplicateAll,
+			expected: []pair{
+				{"a", float64(1)},
+				{"a", float64(2)},
+				{"a", float64(3)},
+				{"b.c", float64(1)},
+				{"b.c", float64(3)},
+				{"b.d", float64(2)},
+				{"e.0.f", float64(1)},
+				{"e.0.f", float64(2)},
+			},
+		},
+		{
+			name: "first",
+			mode: DuplicateFirst,
+			expected: []pair{
+				{"a", float64(1)},
+				{"b.c", float64(1)},
+				{"b.d", float64(2)},
+				{"e.0.f", float64(1)},
+			},
+		},
+		{
+			name: "last",
+			mode: DuplicateLast,
+			expected: []pair{
+				{"a", float64(3)},
+				{"b.c", float64(3)},
+				{"e.0.f", float64(2)},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		for name, f := range flatteners {
+			t.Run(test.name+"/"+name, func(t *testing.T) {
+				pairs, err := collect(t, f, duplicatesJson,
+					WithDuplicateKeys(test.mode))
+				require.NoError(t, err)
+				require.Equal(t, test.expected, pairs)
+			})
+		}
+	}
+}
+
+func TestDuplicateKeysError(t *testing.T) {
+	for name, f := range flatteners {
+		t.Run(name, func(t *testing.T) {
+			_, err := collect(t, f, testJson, WithDuplicateKeys(DuplicateError))
+			require.NoError(t, err)
+
+			_, err = collect(t, f, duplicatesJson,
+				WithDuplicateKeys(DuplicateError))
+			require.ErrorIs(t, err, ErrDuplicateKey)
+		})
+	}
+}
+
+func TestDuplicateKeysLastOrder(t *testing.T) {
+	doc := `[{"a": 1, "b": 2, "a": 3}, {"c": 4}]`
+
+	var keys []string
+	p := NewParserPitr(func(k string, v any) bool {
+		keys = append(keys, k)
+		return true
+	}, WithDuplicateKeys(DuplicateLast))
+
+	err := p.Parse(strings.NewReader(doc))
+	require.NoError(t, err)
+	require.Equal(t, []string{"0.b", "0.a", "1.c"}, keys)
+}
@@ -0,0 +1,45 @@
+package jsonflatten
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmptyKeys(t *testing.T) {
+	tests := []struct {
+		doc      string
+		expected []pair
+	}{
+		{
+			doc:      `{"": 1}`,
+			expected: []pair{{"", float64(1)}},
+		},
+		{
+			doc:      `{"":"x", "a": ""}`,
+			expected: []pair{{"", "x"}, {"a", ""}},
+		},
+		{
+			doc:      `{"a": {"": {"b": "c"}}}`,
+			expected: []pair{{"a..b", "c"}},
+		},
+		{
+			doc:      `{"": {"": ""}}`,
+			expected: []pair{{".", ""}},
+		},
+		{
+			doc:      `[{"": [""]}]`,
+			expected: []pair{{"0..0", ""}},
+		},
+	}
+
+	for _, test := range tests {
+		for name, f := range flatteners {
+			t.Run(test.doc+"/"+name, func(t *testing.T) {
+				pairs, err := collect(t, f, test.doc)
+				require.NoError(t, err)
+				require.Equal(t, test.expected, pairs)
+			})
+		}
+	}
+}
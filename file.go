@@ -0,0 +1,108 @@
+package jsonflatten
+
+import (
+	"bytes"
+	"io"
+	"os"
+)
+
+// parseFile calls parse with the contents of the file at path. On Linux the
+// file is mapped in memory instead of read, so the data is not copied and
+// it is only valid until parse returns. See mapFile for the risks of
+// mapping it.
+func parseFile(path string, parse func([]byte) error) error {
+	b, release, err := mapFile(path)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	return parse(b)
+}
+
+// readFile reads the whole file at path when it can not be mapped.
+func readFile(path string) ([]byte, func(), error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return b, func() {}, nil
+}
+
+// ParseBytes flattens the json document in b. Unless lenient mode is
+// enabled the decoder reads b directly, without copying it to its buffer.
+func (p *ParserV2) ParseBytes(b []byte) error {
+	if p.options.lenient {
+		return p.Parse(bytes.NewReader(b))
+	}
+
+	if limit := p.options.limits.inputSize; limit > 0 && int64(len(b)) > limit {
+		return ErrMaxInputSize
+	}
+
+	// the decoder must not keep b after it returns
+	defer func() { p.tok.dec.Reset(errReader{err: io.EOF}) }()
+
+	return p.parse(bytes.NewBuffer(b))
+}
+
+// ParseFile flattens the json document in the file at path.
+func (p *ParserV2) ParseFile(path string) error {
+	return parseFile(path, p.ParseBytes)
+}
+
+// ParseBytes flattens the json document in b. Unless lenient mode is
+// enabled it is scanned directly, without copying it to the read buffer.
+func (p *ParserFast) ParseBytes(b []byte) error {
+	if p.options.lenient {
+		return p.Parse(bytes.NewReader(b))
+	}
+
+	if limit := p.options.limits.inputSize; limit > 0 && int64(len(b)) > limit {
+		return ErrMaxInputSize
+	}
+
+	p.tok.resetBytes(b)
+	defer func() { p.tok.buf = nil }()
+
+	return p.flatten(&p.tok)
+}
+
+// ParseFile flattens the json document in the file at path.
+func (p *ParserFast) ParseFile(path string) error {
+	return parseFile(path, p.ParseBytes)
+}
+
+// ParseBytes flattens the json document in b. Unless lenient mode is
+// enabled the array elements are scanned directly from b without copying
+// it to the read buffer.
+func (p *Parallel) ParseBytes(b []byte) error {
+	if p.options.lenient {
+		return p.Parse(bytes.NewReader(b))
+	}
+
+	if limit := p.options.limits.inputSize; limit > 0 && int64(len(b)) > limit {
+		return ErrMaxInputSize
+	}
+
+	p.scanner.resetBytes(b)
+	defer p.scanner.reset(nil)
+
+	return p.parse()
+}
+
+// ParseFile flattens the json document in the file at path.
+func (p *Parallel) ParseFile(path string) error {
+	return parseFile(path, p.ParseBytes)
+}
+
+// ParseBytes flattens the records in b.
+func (n *NDJSON) ParseBytes(b []byte) error {
+	return n.Parse(bytes.NewReader(b))
+}
+
+// ParseFile flattens the records in the file at path.
+func (n *NDJSON) ParseFile(path string) error {
+	return parseFile(path, n.ParseBytes)
+}
@@ -0,0 +1,48 @@
+package jsonflatten
+
+import "bytes"
+
+// The tokenizers of Parser, ParserPitr, Memory and MemoryV2, encoding/json
+// Decoder and Pitr tokenizer, only read from an io.Reader and copy the input
+// to their own buffers. ParseBytes gives them b through a bytes.Reader, so b
+// is copied like with Parse, and ParseFile only saves the read system calls.
+
+// ParseBytes flattens the json document in b.
+func (p *Parser) ParseBytes(b []byte) error {
+	return p.Parse(bytes.NewReader(b))
+}
+
+// ParseFile flattens the json document in the file at path.
+func (p *Parser) ParseFile(path string) error {
+	return parseFile(path, p.ParseBytes)
+}
+
+// ParseBytes flattens the json document in b.
+func (p *ParserPitr) ParseBytes(b []byte) error {
+	return p.Parse(bytes.NewReader(b))
+}
+
+// ParseFile flattens the json document in the file at path.
+func (p *ParserPitr) ParseFile(path string) error {
+	return parseFile(path, p.ParseBytes)
+}
+
+// ParseBytes flattens the json document in b.
+func (m *Memory) ParseBytes(b []byte) error {
+	return m.Parse(bytes.NewReader(b))
+}
+
+// ParseFile flattens the json document in the file at path.
+func (m *Memory) ParseFile(path string) error {
+	return parseFile(path, m.ParseBytes)
+}
+
+// ParseBytes flattens the json document in b.
+func (m *MemoryV2) ParseBytes(b []byte) error {
+	return m.Parse(bytes.NewReader(b))
+}
+
+// ParseFile flattens the json document in the file at path.
+func (m *MemoryV2) ParseFile(path string) error {
+	return parseFile(path, m.ParseBytes)
+}
@@ -0,0 +1,98 @@
+package jsonflatten
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type fileFlattener interface {
+	ParseBytes([]byte) error
+	ParseFile(string) error
+}
+
+func TestParseFile(t *testing.T) {
+	dir := t.TempDir()
+	docs := map[string]string{
+		"object": testJson,
+		"array":  arrayDoc(100),
+		"empty":  "",
+	}
+
+	for doc, data := range docs {
+		path := filepath.Join(dir, doc+".json")
+		err := os.WriteFile(path, []byte(data), 0o644)
+		require.NoError(t, err)
+
+		for name, f := range flatteners {
+			t.Run(doc+"/"+name, func(t *testing.T) {
+				expected, err := collect(t, f, data)
+				require.NoError(t, err)
+
+				var pairs []pair
+				p := f(func(k string, v any) bool {
+					pairs = append(pairs, pair{Key: k, Value: v})
+					return true
+				}).(fileFlattener)
+
+				err = p.ParseFile(path)
+				require.NoError(t, err)
+				sortPairs(pairs)
+				require.Equal(t, expected, pairs)
+
+				pairs = nil
+				err = p.ParseBytes([]byte(data))
+				require.NoError(t, err)
+				sortPairs(pairs)
+				require.Equal(t, expected, pairs)
+			})
+		}
+	}
+
+	p := NewParserPitr(nil)
+	err := p.ParseFile(filepath.Join(dir, "missing.json"))
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestParallelParseBytes(t *testing.T) {
+	doc := arrayDoc(100)
+
+	p := NewParallel(nil, WithMaxInputSize(10))
+	err := p.ParseBytes([]byte(doc))
+	require.ErrorIs(t, err, ErrMaxInputSize)
+
+	var count int
+	p = NewParallel(func(k string, v any) bool {
+		count++
+		return true
+	}, WithLenient(true))
+	err = p.ParseBytes([]byte(strings.Replace(doc, "[", "[// comment\n", 1)))
+	require.NoError(t, err)
+	require.Equal(t, 400, count)
+}
+
+func TestParseBytesConformance(t *testing.T) {
+	// ParseBytes reads b directly in some flatteners, the results must be
+	// the same as with Parse
+	for _, test := range conformanceDocs {
+		for name, f := range flatteners {
+			t.Run(test.name+"/"+name, func(t *testing.T) {
+				var pairs []pair
+				p := f(func(k string, v any) bool {
+					pairs = append(pairs, pair{Key: k, Value: v})
+					return true
+				}).(fileFlattener)
+
+				err := p.ParseBytes([]byte(test.doc))
+				require.Equal(t, test.class, errorClass(t, err), "error: %v", err)
+				if test.class == "" {
+					sortPairs(pairs)
+					require.Equal(t, test.expected, pairs)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package jsonflatten
+
+import (
+	"bytes"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// fuzzSeeds are added to the seed corpus with the conformance documents.
+var fuzzSeeds = []string{
+	testJson,
+	`{"a": {"b": [1, 2, {"c": "d"}]}, "e": null}`,
+	`[{"a": 1}, {"a": 2}, [3, [4]]]`,
+	`{"a": 1} {"b": 2}`,
+	`{"a": "é😀\n\"", "\u0000": ""}`,
+	`{"a": [1e10, -0.5, 1E-5, 0]}`,
+	`{"a": [true, false, null]}`,
+	`[[[[[]]]]]`,
+	"{\"a\": \"\xff\xfe\"}",
+	`{"a": "\udc00"}`,
+	`{"a": 1,}`,
+	`{"a": [1, 2`,
+}
+
+// outcome is the result of flattening a document.
+type outcome struct {
+	pairs []pair
+	err   error
+}
+
+// flattenAll flattens data with every flattener. The depth is limited so
+// the tokenizers with a maximum depth do not differ.
+func flattenAll(data []byte) map[string]outcome {
+	results := make(map[string]outcome, len(flatteners))
+	for name, f := range flatteners {
+		var pairs []pair
+		p := f(func(k string, v any) bool {
+			pairs = append(pairs, pair{Key: k, Value: v})
+			return true
+		}, WithMaxDepth(1000))
+
+		err := p.Parse(bytes.NewReader(data))
+		sortPairs(pairs)
+		results[name] = outcome{pairs: pairs, err: err}
+	}
+
+	return results
+}
+
+// differences compares the outcomes with the one of Parser. When all the
+// flatteners succeed the pairs must be the same, otherwise all of them
+// must fail. It returns an empty string if they agree. The report only
+// depends on the input so the fuzzer can minimize it.
+func differences(data []byte, results map[string]outcome) string {
+	reference := results["v1"]
+	failed := reference.err != nil
+	for _, r := range results {
+		failed = failed || r.err != nil
+	}
+
+	var report strings.Builder
+	for _, name := range slices.Sorted(maps.Keys(results)) {
+		r := results[name]
+
+		switch {
+		case failed && (r.err == nil) != (reference.err == nil):
+			fmt.Fprintf(&report, "%s: error %v, v1: error %v\n",
+				name, r.err, reference.err)
+
+		case !failed && !slices.Equal(r.pairs, reference.pairs):
+			fmt.Fprintf(&report, "%s: %s\n", name,
+				firstDifference(r.pairs, reference.pairs))
+		}
+	}
+
+	if report.Len() == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf("input %q\n%s", data, report.String())
+}
+
+// firstDifference describes the first pair that is not the same in a and
+// the reference pairs b.
+func firstDifference(a, b []pair) string {
+	for i := range min(len(a), len(b)) {
+		if a[i] != b[i] {
+			return fmt.Sprintf("pair %d is %q=%#v, v1: %q=%#v",
+				i, a[i].Key, a[i].Value, b[i].Key, b[i].Value)
+		}
+	}
+
+	return fmt.Sprintf("%d pairs, v1: %d pairs", len(a), len(b))
+}
+
+// FuzzFlatteners feeds the same input to all the flatteners and fails when
+// they do not agree.
+func FuzzFlatteners(f *testing.F) {
+	for _, doc := range fuzzSeeds {
+		f.Add([]byte(doc))
+	}
+	for _, c := range conformanceDocs {
+		f.Add([]byte(c.doc))
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if report := differences(data, flattenAll(data)); report != "" {
+			t.Fatal(report)
+		}
+	})
+}
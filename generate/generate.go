@@ -0,0 +1,221 @@
+// Package generate writes synthetic json documents for tests and
+// benchmarks. The documents only depend on the Shape, the same shape and
+// seed always produce the same bytes.
+package generate
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"math/rand/v2"
+	"slices"
+	"strconv"
+)
+
+// Mix sets the relative weight of each kind of value. Objects and arrays
+// are not generated at the maximum depth.
+type Mix struct {
+	Objects   int
+	Arrays    int
+	Strings   int
+	Integers  int
+	Floats    int
+	Exponents int
+	Bools     int
+	Nulls     int
+}
+
+// DefaultMix is used when the Mix of a Shape is empty.
+var DefaultMix = Mix{
+	Objects:  1,
+	Arrays:   1,
+	Strings:  4,
+	Integers: 2,
+	Floats:   1,
+	Bools:    1,
+	Nulls:    1,
+}
+
+// Shape describes the generated document.
+type Shape struct {
+	// Seed of the random generator.
+	Seed uint64
+	// Records is the number of objects of the root array. With zero the
+	// root is a single object.
+	Records int
+	// Depth is the maximum nesting depth of the objects and arrays, the
+	// root is depth 1.
+	Depth int
+	// Width is the number of members of each object.
+	Width int
+	// ArrayLength is the number of elements of each array.
+	ArrayLength int
+	// StringSize is the maximum length of the strings.
+	StringSize int
+	// Escapes is the fraction of strings that have an escape sequence.
+	Escapes float64
+	// Mix sets the kinds of values.
+	Mix Mix
+}
+
+// Generate returns the document described by s.
+func Generate(s Shape) []byte {
+	var buf bytes.Buffer
+
+	// writing to a bytes.Buffer does not fail
+	_ = Write(&buf, s)
+
+	return buf.Bytes()
+}
+
+// Write writes the document described by s to w.
+func Write(w io.Writer, s Shape) error {
+	if s.Mix == (Mix{}) {
+		s.Mix = DefaultMix
+	}
+
+	g := &generator{
+		w:     bufio.NewWriter(w),
+		rnd:   rand.New(rand.NewPCG(s.Seed, s.Seed)),
+		shape: s,
+	}
+
+	if s.Records > 0 {
+		g.w.WriteByte('[')
+		for i := range s.Records {
+			if i > 0 {
+				g.w.WriteString(",\n")
+			}
+			g.object(1)
+		}
+		g.w.WriteByte(']')
+	} else {
+		g.object(1)
+	}
+	g.w.WriteByte('\n')
+
+	return g.w.Flush()
+}
+
+// keyNames are the object keys, keys after the last one get a number.
+var keyNames = []string{
+	"id", "name", "type", "value", "created_at", "url", "count", "enabled",
+	"tags", "owner", "payload", "size", "description", "status", "score",
+	"items",
+}
+
+const letters = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789 "
+
+// escapes are the escape sequences added to strings.
+var escapes = []string{`\"`, `\\`, `\n`, `\t`, `\/`, `\u00e9`, `\ud83d\ude00`}
+
+type generator struct {
+	w     *bufio.Writer
+	rnd   *rand.Rand
+	shape Shape
+	buf   []byte
+}
+
+func (g *generator) object(depth int) {
+	g.w.WriteByte('{')
+	for i := range g.shape.Width {
+		if i > 0 {
+			g.w.WriteByte(',')
+		}
+
+		g.w.WriteByte('"')
+		g.w.WriteString(keyNames[i%len(keyNames)])
+		if i >= len(keyNames) {
+			g.w.WriteString(strconv.Itoa(i / len(keyNames)))
+		}
+		g.w.WriteString(`":`)
+
+		g.value(depth + 1)
+	}
+	g.w.WriteByte('}')
+}
+
+func (g *generator) array(depth int) {
+	g.w.WriteByte('[')
+	for i := range g.shape.ArrayLength {
+		if i > 0 {
+			g.w.WriteByte(',')
+		}
+		g.value(depth + 1)
+	}
+	g.w.WriteByte(']')
+}
+
+// value writes a random value at depth.
+func (g *generator) value(depth int) {
+	m := g.shape.Mix
+	if depth >= g.shape.Depth {
+		m.Objects = 0
+		m.Arrays = 0
+	}
+
+	weights := [...]int{
+		m.Objects, m.Arrays, m.Strings, m.Integers,
+		m.Floats, m.Exponents, m.Bools, m.Nulls,
+	}
+
+	total := 0
+	for _, w := range weights {
+		total += w
+	}
+	if total == 0 {
+		g.w.WriteString("null")
+		return
+	}
+
+	n := g.rnd.IntN(total)
+	kind := 0
+	for n >= weights[kind] {
+		n -= weights[kind]
+		kind++
+	}
+
+	switch kind {
+	case 0:
+		g.object(depth)
+	case 1:
+		g.array(depth)
+	case 2:
+		g.string()
+	case 3:
+		g.number(strconv.AppendInt(g.buf[:0], g.rnd.Int64N(2e9)-1e9, 10))
+	case 4:
+		v := (g.rnd.Float64() - 0.5) * 1e6
+		g.number(strconv.AppendFloat(g.buf[:0], v, 'f', 1+g.rnd.IntN(6), 64))
+	case 5:
+		v := (g.rnd.Float64() - 0.5) * float64(g.rnd.Int64N(1e15)+1)
+		g.number(strconv.AppendFloat(g.buf[:0], v, 'e', -1, 64))
+	case 6:
+		g.w.WriteString(strconv.FormatBool(g.rnd.IntN(2) == 0))
+	default:
+		g.w.WriteString("null")
+	}
+}
+
+func (g *generator) number(b []byte) {
+	g.buf = b
+	g.w.Write(b)
+}
+
+func (g *generator) string() {
+	b := g.buf[:0]
+	for range g.rnd.IntN(g.shape.StringSize + 1) {
+		b = append(b, letters[g.rnd.IntN(len(letters))])
+	}
+
+	if g.shape.Escapes > 0 && g.rnd.Float64() < g.shape.Escapes {
+		i := g.rnd.IntN(len(b) + 1)
+		e := escapes[g.rnd.IntN(len(escapes))]
+		b = slices.Insert(b, i, []byte(e)...)
+	}
+	g.buf = b
+
+	g.w.WriteByte('"')
+	g.w.Write(b)
+	g.w.WriteByte('"')
+}
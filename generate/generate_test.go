@@ -0,0 +1,82 @@
+package generate
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerate(t *testing.T) {
+	shape := Shape{
+		Seed:        42,
+		Depth:       4,
+		Width:       20,
+		ArrayLength: 3,
+		StringSize:  16,
+		Escapes:     0.5,
+		Mix: Mix{
+			Objects:   1,
+			Arrays:    1,
+			Strings:   1,
+			Integers:  1,
+			Floats:    1,
+			Exponents: 1,
+			Bools:     1,
+			Nulls:     1,
+		},
+	}
+
+	doc := Generate(shape)
+	require.True(t, json.Valid(doc))
+	require.Equal(t, doc, Generate(shape))
+
+	var v map[string]any
+	require.NoError(t, json.Unmarshal(doc, &v))
+	require.Len(t, v, 20)
+	require.Contains(t, v, "id")
+	require.Contains(t, v, "id1")
+	require.LessOrEqual(t, depth(v), 4)
+
+	shape.Seed = 43
+	require.NotEqual(t, doc, Generate(shape))
+}
+
+func TestGenerateRecords(t *testing.T) {
+	doc := Generate(Shape{Records: 10, Depth: 2, Width: 3, StringSize: 8})
+	require.True(t, json.Valid(doc))
+
+	var v []map[string]any
+	require.NoError(t, json.Unmarshal(doc, &v))
+	require.Len(t, v, 10)
+	for _, r := range v {
+		require.Len(t, r, 3)
+		require.LessOrEqual(t, depth(r), 2)
+	}
+}
+
+func TestGenerateMix(t *testing.T) {
+	// containers are not generated at the maximum depth
+	doc := Generate(Shape{Depth: 1, Width: 5, Mix: Mix{Objects: 1}})
+	require.JSONEq(t,
+		`{"id": null, "name": null, "type": null, "value": null, "created_at": null}`,
+		string(doc))
+}
+
+func depth(v any) int {
+	d := 0
+	switch v := v.(type) {
+	case map[string]any:
+		for _, e := range v {
+			d = max(d, depth(e))
+		}
+	case []any:
+		for _, e := range v {
+			d = max(d, depth(e))
+		}
+	default:
+		return 0
+	}
+
+	return d + 1
+}
@@ -0,0 +1,582 @@
+package jsonflatten
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// gronRoot is the name of the root value in gron statements.
+const gronRoot = "json"
+
+// gronReserved are the JavaScript reserved words. Keys with these names are
+// written with brackets like gron does.
+var gronReserved = map[string]bool{
+	"break": true, "case": true, "catch": true, "class": true, "const": true,
+	"continue": true, "debugger": true, "default": true, "delete": true,
+	"do": true, "else": true, "export": true, "extends": true, "false": true,
+	"finally": true, "for": true, "function": true, "if": true,
+	"import": true, "in": true, "instanceof": true, "new": true,
+	"null": true, "return": true, "super": true, "switch": true,
+	"this": true, "throw": true, "true": true, "try": true, "typeof": true,
+	"var": true, "void": true, "while": true, "with": true, "yield": true,
+}
+
+// Gron writes json documents as gron statements, a JavaScript assignment
+// for each value and container:
+//
+//	json = {};
+//	json.glossary = {};
+//	json.glossary.tags = [];
+//	json.glossary.tags[0] = "GML";
+//	json.glossary.title = "example glossary";
+//	json["Gloss Entry"] = "x";
+//
+// Keys that are not identifiers are quoted in brackets. The documents are
+// read by a flattener configured with WithGron, so the limits, lenient mode
+// and the duplicate and UTF-8 policies apply like for the other emitters,
+// and root values that are not objects or arrays are not supported. The
+// statements of each document are sorted like gron does, numbers are
+// formatted like encoding/json instead of keeping their text. The output can
+// be converted back to json with Ungron.
+type Gron struct {
+	// InOrder writes the statements in document order as they are parsed
+	// instead of keeping each document in memory to sort them.
+	InOrder bool
+
+	w   *bufio.Writer
+	p   *ParserFast
+	err error
+
+	// buf has the statements of the current document and lines their
+	// positions to sort them when it ends
+	buf   []byte
+	lines []gronLine
+}
+
+// gronLine is a statement in the buffer. path is the end of its path.
+type gronLine struct {
+	start, path, end int
+}
+
+// kindObject and kindArray are the values sent to Gron when a container
+// starts. Only Gron receives them.
+const (
+	kindObject Kind = KindBool + 1 + iota
+	kindArray
+)
+
+// NewGron creates a Gron that writes the statements to w. Parse reads the
+// documents with ParserFast and the options opts.
+func NewGron(w io.Writer, opts ...Option) *Gron {
+	g := &Gron{
+		w: bufio.NewWriter(w),
+	}
+	g.p = NewParserFast(nil, append(slices.Clip(opts), WithGron(g))...)
+
+	return g
+}
+
+// WithGron sends the values and the containers to g instead of the emitter.
+// The keys are written with gron syntax and arrays always use ArrayIndex.
+// Call g.Flush after parsing to write the output. It is not supported by
+// NDJSON and Parallel flattens the input sequentially:
+//
+//	g := jsonflatten.NewGron(os.Stdout)
+//	p := jsonflatten.NewParserPitr(nil, jsonflatten.WithGron(g))
+//	err := p.Parse(r)
+//	if ferr := g.Flush(); err == nil {
+//		err = ferr
+//	}
+func WithGron(g *Gron) Option {
+	return func(o *options) {
+		o.gron = g
+	}
+}
+
+// Parse reads the json documents from r, writes their statements and
+// flushes the output.
+func (g *Gron) Parse(r io.Reader) error {
+	err := g.p.Parse(r)
+	if ferr := g.Flush(); err == nil {
+		err = ferr
+	}
+
+	return err
+}
+
+// Flush writes the statements of a document that did not end, when parsing
+// failed, and flushes the output. It returns the first write error.
+func (g *Gron) Flush() error {
+	g.end()
+	if err := g.w.Flush(); g.err == nil {
+		g.err = err
+	}
+
+	return g.err
+}
+
+// emit adds the statement of a value or the start of a container. It
+// returns false after a write error.
+func (g *Gron) emit(key []byte, v Value) bool {
+	if g.err != nil {
+		return false
+	}
+
+	start := len(g.buf)
+	g.buf = append(g.buf, gronRoot...)
+	g.buf = append(g.buf, key...)
+	path := len(g.buf)
+
+	g.buf = append(g.buf, " = "...)
+	switch v.Kind {
+	case kindObject:
+		g.buf = append(g.buf, "{}"...)
+	case kindArray:
+		g.buf = append(g.buf, "[]"...)
+	default:
+		g.buf = appendJSON(g.buf, v)
+	}
+	g.buf = append(g.buf, ";\n"...)
+
+	if !g.InOrder {
+		g.lines = append(g.lines, gronLine{start: start, path: path, end: len(g.buf)})
+		return true
+	}
+
+	_, g.err = g.w.Write(g.buf)
+	g.buf = g.buf[:0]
+
+	return g.err == nil
+}
+
+// end writes the statements of the document, sorted by path. Statements
+// with the same path, from repeated keys, keep their order.
+func (g *Gron) end() bool {
+	slices.SortStableFunc(g.lines, func(a, b gronLine) int {
+		return compareGronPaths(
+			unsafeString(g.buf[a.start+len(gronRoot):a.path]),
+			unsafeString(g.buf[b.start+len(gronRoot):b.path]),
+		)
+	})
+
+	for _, l := range g.lines {
+		if g.err != nil {
+			break
+		}
+		_, g.err = g.w.Write(g.buf[l.start:l.end])
+	}
+
+	g.buf = g.buf[:0]
+	g.lines = g.lines[:0]
+
+	return g.err == nil
+}
+
+// gronContainer sends the start of a container to Gron with its key in the
+// parent, the root container has an empty key.
+func (p *commonParser) gronContainer(t Type) bool {
+	v := Value{Kind: kindObject}
+	if t == TypeArray {
+		v.Kind = kindArray
+	}
+
+	if len(p.States) == 0 {
+		return p.call(p.path[:0], v)
+	}
+
+	return p.emit(p.lastState(), v)
+}
+
+// compareGronPaths compares two gron paths key by key like gron sorts them:
+// a path goes before the paths inside it, properties before brackets,
+// indexes by their value and the other keys by their text.
+func compareGronPaths(a, b string) int {
+	for a != "" && b != "" {
+		ka, kb := gronSegment(a), gronSegment(b)
+		if ka != kb {
+			return compareGronKeys(ka, kb)
+		}
+		a, b = a[len(ka):], b[len(kb):]
+	}
+
+	return cmp.Compare(len(a), len(b))
+}
+
+// gronSegment returns the first key of a gron path, a property or a key or
+// index in brackets.
+func gronSegment(path string) string {
+	switch {
+	case strings.HasPrefix(path, `["`):
+		return path[:stringEnd(path, 1)+1]
+	case path[0] == '[':
+		return path[:strings.IndexByte(path, ']')+1]
+	}
+
+	i := 1
+	for i < len(path) && path[i] != '.' && path[i] != '[' {
+		i++
+	}
+
+	return path[:i]
+}
+
+func compareGronKeys(a, b string) int {
+	if a[0] != b[0] || a[0] == '.' {
+		return strings.Compare(a, b)
+	}
+
+	a, b = a[1:len(a)-1], b[1:len(b)-1]
+	if a[0] != '"' && b[0] != '"' && len(a) != len(b) {
+		// indexes do not have leading zeros
+		return cmp.Compare(len(a), len(b))
+	}
+
+	return strings.Compare(a, b)
+}
+
+// appendGronKey appends an object key to the path, as a property if it is
+// an identifier or quoted in brackets otherwise.
+func appendGronKey(dst []byte, key string) []byte {
+	if validIdentifier(key) {
+		dst = append(dst, '.')
+		return append(dst, key...)
+	}
+
+	dst = append(dst, '[')
+	dst = appendQuoted(dst, key)
+	return append(dst, ']')
+}
+
+func appendGronIndex(dst []byte, i int) []byte {
+	dst = append(dst, '[')
+	dst = strconv.AppendInt(dst, int64(i), 10)
+	return append(dst, ']')
+}
+
+// validIdentifier returns true when s can be used as a JavaScript property
+// name without quotes.
+func validIdentifier(s string) bool {
+	if s == "" || gronReserved[s] {
+		return false
+	}
+
+	for i, r := range s {
+		switch {
+		case r == '_', r == '$', unicode.IsLetter(r):
+		case i > 0 && unicode.IsDigit(r):
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+// Ungron reads gron statements from r and writes the json document they
+// describe to w, indented with two spaces. Each line assigns a value to a
+// path that starts with the root name, usually "json":
+//
+//	json.glossary.title = "example glossary";
+//	json.glossary.tags[1] = "XML";
+//
+// The containers are created by the first path that uses them, so the "{}"
+// and "[]" statements are optional. Keys keep the order in which they are
+// first assigned. Array elements are added in order like gron writes them,
+// an index can be at most the length of the array. Invalid lines fail with
+// ErrSyntax and the line number.
+func Ungron(r io.Reader, w io.Writer) error {
+	var root *gronNode
+
+	br := bufio.NewReader(r)
+	for n := 1; ; n++ {
+		line, err := br.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			if perr := parseGronLine(&root, line); perr != nil {
+				return fmt.Errorf("%w: line %d: %w", ErrSyntax, n, perr)
+			}
+		}
+
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if root == nil {
+		return nil
+	}
+
+	bw := bufio.NewWriter(w)
+	b := root.appendJSON(nil)
+	b = append(b, '\n')
+	bw.Write(b)
+
+	return bw.Flush()
+}
+
+// gronNode is a value of the document built by Ungron.
+type gronNode struct {
+	kind Type
+	// raw is the json of a value that is not a container
+	raw []byte
+
+	// values are the fields of an object, with their keys and the index
+	// of each key, or the elements of an array
+	keys   []string
+	fields map[string]int
+	values []*gronNode
+}
+
+// parseGronLine parses a statement and assigns its value in the document.
+func parseGronLine(root **gronNode, line []byte) error {
+	s := string(bytes.TrimSpace(line))
+
+	// root name
+	i := 0
+	for i < len(s) {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r != '_' && r != '$' && !unicode.IsLetter(r) &&
+			(i == 0 || !unicode.IsDigit(r)) {
+			break
+		}
+		i += size
+	}
+	if i == 0 {
+		return errors.New("missing root name")
+	}
+
+	node := root
+	for i < len(s) && s[i] != ' ' && s[i] != '=' {
+		switch s[i] {
+		case '.':
+			j := i + 1
+			for j < len(s) && s[j] != '.' && s[j] != '[' && s[j] != ' ' && s[j] != '=' {
+				j++
+			}
+			if !validIdentifier(s[i+1 : j]) {
+				return fmt.Errorf("invalid key %q", s[i+1:j])
+			}
+			node = gronField(node, s[i+1:j])
+			i = j
+
+		case '[':
+			if i+1 < len(s) && s[i+1] == '"' {
+				end := stringEnd(s, i+1)
+				if end < 0 || end >= len(s) || s[end] != ']' {
+					return errors.New("invalid quoted key")
+				}
+
+				var key string
+				if err := json.Unmarshal([]byte(s[i+1:end]), &key); err != nil {
+					return fmt.Errorf("invalid quoted key: %w", err)
+				}
+				node = gronField(node, key)
+				i = end + 1
+				break
+			}
+
+			j := i + 1
+			for j < len(s) && s[j] >= '0' && s[j] <= '9' {
+				j++
+			}
+			index, err := strconv.Atoi(s[i+1 : j])
+			if err != nil || j >= len(s) || s[j] != ']' {
+				return fmt.Errorf("invalid index %q", s[i:min(j+1, len(s))])
+			}
+			node, err = gronItem(node, index)
+			if err != nil {
+				return err
+			}
+			i = j + 1
+
+		default:
+			return fmt.Errorf("invalid character %q in path", s[i])
+		}
+
+		if node == nil {
+			return errors.New("path goes through a value that is not a container")
+		}
+	}
+
+	rest := bytes.TrimSpace([]byte(s[i:]))
+	if len(rest) == 0 || rest[0] != '=' {
+		return errors.New("missing =")
+	}
+
+	value := bytes.TrimSpace(rest[1:])
+	value = bytes.TrimSpace(bytes.TrimSuffix(value, []byte(";")))
+
+	return assignGron(node, value)
+}
+
+// assignGron sets the value of a node. Assigning {} or [] to a container of
+// the same type keeps its contents.
+func assignGron(node **gronNode, value []byte) error {
+	var kind Type
+	switch string(value) {
+	case "{}":
+		kind = TypeObject
+	case "[]":
+		kind = TypeArray
+	default:
+		if !json.Valid(value) {
+			return fmt.Errorf("invalid value %q", value)
+		}
+		*node = &gronNode{raw: bytes.Clone(value)}
+		return nil
+	}
+
+	if *node == nil || (*node).kind != kind {
+		*node = &gronNode{kind: kind}
+		if kind == TypeObject {
+			(*node).fields = make(map[string]int)
+		}
+	}
+
+	return nil
+}
+
+// gronField returns the slot of key in the object at node, creating the
+// object and the field if needed. It returns nil if the node is another
+// value.
+func gronField(node **gronNode, key string) **gronNode {
+	n := *node
+	if n == nil {
+		n = &gronNode{kind: TypeObject, fields: make(map[string]int)}
+		*node = n
+	}
+	if n.kind != TypeObject {
+		return nil
+	}
+
+	i, ok := n.fields[key]
+	if !ok {
+		i = len(n.keys)
+		n.fields[key] = i
+		n.keys = append(n.keys, key)
+		n.values = append(n.values, nil)
+	}
+
+	return &n.values[i]
+}
+
+// gronItem returns the slot of element i of the array at node, creating the
+// array and the element if needed. It returns nil if the node is another
+// value and an error if i skips elements.
+func gronItem(node **gronNode, i int) (**gronNode, error) {
+	n := *node
+	if n == nil {
+		n = &gronNode{kind: TypeArray}
+		*node = n
+	}
+	if n.kind != TypeArray {
+		return nil, nil
+	}
+
+	switch {
+	case i == len(n.values):
+		n.values = append(n.values, nil)
+	case i > len(n.values):
+		return nil, fmt.Errorf("index %d after %d elements", i, len(n.values))
+	}
+
+	return &n.values[i], nil
+}
+
+// appendJSON appends the node as indented json. The open containers are
+// kept in a stack instead of recursing, so the depth is not limited.
+func (n *gronNode) appendJSON(dst []byte) []byte {
+	// each open container with the index of its next value
+	type open struct {
+		n    *gronNode
+		next int
+	}
+	var stack []open
+
+	for {
+		switch {
+		case n == nil:
+			dst = append(dst, "null"...)
+		case n.kind == TypeObject && len(n.keys) == 0:
+			dst = append(dst, "{}"...)
+		case n.kind == TypeArray && len(n.values) == 0:
+			dst = append(dst, "[]"...)
+		case n.kind == TypeObject:
+			dst = append(dst, '{')
+			stack = append(stack, open{n: n})
+		case n.kind == TypeArray:
+			dst = append(dst, '[')
+			stack = append(stack, open{n: n})
+		default:
+			dst = append(dst, n.raw...)
+		}
+
+		// close the finished containers and find the next value
+		for {
+			if len(stack) == 0 {
+				return dst
+			}
+
+			o := &stack[len(stack)-1]
+			if o.next == len(o.n.values) {
+				stack = stack[:len(stack)-1]
+				dst = appendIndent(dst, len(stack))
+				if o.n.kind == TypeObject {
+					dst = append(dst, '}')
+				} else {
+					dst = append(dst, ']')
+				}
+				continue
+			}
+
+			if o.next > 0 {
+				dst = append(dst, ',')
+			}
+			dst = appendIndent(dst, len(stack))
+			if o.n.kind == TypeObject {
+				dst = appendQuoted(dst, o.n.keys[o.next])
+				dst = append(dst, ": "...)
+			}
+
+			n = o.n.values[o.next]
+			o.next++
+			break
+		}
+	}
+}
+
+func appendIndent(dst []byte, depth int) []byte {
+	dst = append(dst, '\n')
+	for range depth {
+		dst = append(dst, "  "...)
+	}
+
+	return dst
+}
+
+// stringEnd returns the index after the json string that starts at i, or -1
+// if it is not closed.
+func stringEnd(s string, i int) int {
+	for j := i + 1; j < len(s); j++ {
+		switch s[j] {
+		case '\\':
+			j++
+		case '"':
+			return j + 1
+		}
+	}
+
+	return -1
+}
@@ -0,0 +1,227 @@
+package jsonflatten
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGron(t *testing.T) {
+	doc := `{
+		"glossary": {"title": "example glossary", "tags": ["GML", "XML"]},
+		"Gloss Entry": {"": null, "new": true, "1st": 1e21},
+		"empty": {"object": {}, "array": []},
+		"text": "a \"b\"\n",
+		"ünï_$0": [[1], {"x": 2}]
+	}`
+
+	expected := `json = {};
+json.empty = {};
+json.empty.array = [];
+json.empty.object = {};
+json.glossary = {};
+json.glossary.tags = [];
+json.glossary.tags[0] = "GML";
+json.glossary.tags[1] = "XML";
+json.glossary.title = "example glossary";
+json.text = "a \"b\"\n";
+json.ünï_$0 = [];
+json.ünï_$0[0] = [];
+json.ünï_$0[0][0] = 1;
+json.ünï_$0[1] = {};
+json.ünï_$0[1].x = 2;
+json["Gloss Entry"] = {};
+json["Gloss Entry"][""] = null;
+json["Gloss Entry"]["1st"] = 1e+21;
+json["Gloss Entry"]["new"] = true;
+`
+
+	var buf bytes.Buffer
+	g := NewGron(&buf)
+	require.NoError(t, g.Parse(strings.NewReader(doc)))
+	require.Equal(t, expected, buf.String())
+
+	var out bytes.Buffer
+	require.NoError(t, Ungron(&buf, &out))
+	require.JSONEq(t, doc, out.String())
+
+	// the statements of each document are sorted, indexes by value
+	buf.Reset()
+	require.NoError(t, g.Parse(strings.NewReader(
+		`[0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10] {"b": 1, "a": 2}`)))
+	require.Equal(t, "json = [];\njson[0] = 0;\njson[1] = 1;\njson[2] = 2;\n"+
+		"json[3] = 3;\njson[4] = 4;\njson[5] = 5;\njson[6] = 6;\n"+
+		"json[7] = 7;\njson[8] = 8;\njson[9] = 9;\njson[10] = 10;\n"+
+		"json = {};\njson.a = 2;\njson.b = 1;\n", buf.String())
+
+	buf.Reset()
+	g.InOrder = true
+	require.NoError(t, g.Parse(strings.NewReader(`{"b": [1], "a": 2}`)))
+	require.Equal(t, "json = {};\njson.b = [];\njson.b[0] = 1;\njson.a = 2;\n",
+		buf.String())
+
+	buf.Reset()
+	require.NoError(t, g.Parse(strings.NewReader(``)))
+	require.Equal(t, "", buf.String())
+
+	err := g.Parse(strings.NewReader(`"x"`))
+	require.ErrorIs(t, err, errors.ErrUnsupported)
+
+	err = g.Parse(strings.NewReader(`{"a": [1`))
+	require.ErrorIs(t, err, ErrTruncated)
+
+	err = g.Parse(strings.NewReader(`{"a": 1]`))
+	require.ErrorIs(t, err, ErrSyntax)
+}
+
+func TestGronOptions(t *testing.T) {
+	tests := []struct {
+		name     string
+		doc      string
+		opts     []Option
+		expected string
+		err      error
+	}{
+		{
+			name:     "duplicate last",
+			doc:      `{"a": {"x": 1}, "b": 2, "a": [3]}`,
+			opts:     []Option{WithDuplicateKeys(DuplicateLast)},
+			expected: "json = {};\njson.a = [];\njson.a[0] = 3;\njson.b = 2;\n",
+		},
+		{
+			name:     "duplicate first",
+			doc:      `{"a": {"x": 1}, "b": 2, "a": [3]}`,
+			opts:     []Option{WithDuplicateKeys(DuplicateFirst)},
+			expected: "json = {};\njson.a = {};\njson.a.x = 1;\njson.b = 2;\n",
+		},
+		{
+			name: "duplicate error",
+			doc:  `{"a b": 1, "a b": 2}`,
+			opts: []Option{WithDuplicateKeys(DuplicateError)},
+			err:  ErrDuplicateKey,
+		},
+		{
+			name:     "lenient",
+			doc:      "{'a': [1,], // comment\n}",
+			opts:     []Option{WithLenient(true)},
+			expected: "json = {};\njson.a = [];\njson.a[0] = 1;\n",
+		},
+		{
+			name:     "array mode",
+			doc:      `{"a": [1]}`,
+			opts:     []Option{WithArrayMode(ArrayJoin), WithSeparator("/")},
+			expected: "json = {};\njson.a = [];\njson.a[0] = 1;\n",
+		},
+		{
+			name: "max depth",
+			doc:  `{"a": {"b": 1}}`,
+			opts: []Option{WithMaxDepth(1)},
+			err:  ErrMaxDepth,
+		},
+		{
+			name: "invalid UTF-8",
+			doc:  "{\"a\": \"\xff\"}",
+			opts: []Option{WithInvalidUTF8(UTF8Reject)},
+			err:  ErrInvalidUTF8,
+		},
+		{
+			name: "strict",
+			doc:  `{} {}`,
+			opts: []Option{WithStrict(true)},
+			err:  ErrTrailingData,
+		},
+	}
+
+	for _, test := range tests {
+		for name, f := range flatteners {
+			t.Run(test.name+"/"+name, func(t *testing.T) {
+				var buf bytes.Buffer
+				g := NewGron(&buf)
+				p := f(nil, append(test.opts, WithGron(g))...)
+
+				err := p.Parse(strings.NewReader(test.doc))
+				require.NoError(t, g.Flush())
+				if test.err != nil {
+					require.ErrorIs(t, err, test.err)
+					return
+				}
+
+				require.NoError(t, err)
+				require.Equal(t, test.expected, buf.String())
+			})
+		}
+	}
+
+	err := NewNDJSON(nil, WithGron(NewGron(io.Discard))).
+		Parse(strings.NewReader(`{"a": 1}`))
+	require.ErrorIs(t, err, errors.ErrUnsupported)
+}
+
+func TestUngron(t *testing.T) {
+	// containers are created by the paths that use them
+	in := `
+json.b[0].c = "x";
+json.a = 1;
+json["b"][1] = true;
+json.b[0].d = null;
+json = {};
+json.a = 2;
+`
+	expected := `{
+  "b": [
+    {
+      "c": "x",
+      "d": null
+    },
+    true
+  ],
+  "a": 2
+}
+`
+
+	var out bytes.Buffer
+	require.NoError(t, Ungron(strings.NewReader(in), &out))
+	require.Equal(t, expected, out.String())
+
+	out.Reset()
+	require.NoError(t, Ungron(strings.NewReader(""), &out))
+	require.Equal(t, "", out.String())
+
+	invalid := []string{
+		`json.a = ;`,
+		`json.a = x;`,
+		`json.a 1;`,
+		`json..a = 1;`,
+		`json.a[x] = 1;`,
+		`json["a] = 1;`,
+		`= 1;`,
+		"json.a = 1;\njson.a.b = 2;",
+		"json.a = [];\njson.a.b = 2;",
+		"json = [];\njson[\"b\"] = 2;",
+		"json.a = 1;\njson.a[\"b\"] = 2;",
+		`json.a[1] = 1;`,
+		`json[300000000] = 1;`,
+		"json.a[0] = 1;\njson.a[2] = 1;",
+	}
+
+	for _, in := range invalid {
+		err := Ungron(strings.NewReader(in), &out)
+		require.ErrorIs(t, err, ErrSyntax, in)
+	}
+}
+
+func TestUngronDepth(t *testing.T) {
+	const depth = 3000
+
+	in := "json" + strings.Repeat("[0]", depth) + " = 1;\n"
+
+	var out bytes.Buffer
+	require.NoError(t, Ungron(strings.NewReader(in), &out))
+	require.True(t, json.Valid(out.Bytes()))
+	require.Equal(t, depth, strings.Count(out.String(), "["))
+}
@@ -0,0 +1,43 @@
+package jsonflatten
+
+import "strings"
+
+const (
+	// maxInterned is the maximum number of keys kept by the intern table.
+	maxInterned = 4096
+	// maxInternedLength is the length of the longest key that is interned.
+	maxInternedLength = 256
+)
+
+// internTable keeps the object keys already decoded so repeated keys, like
+// the ones in arrays of objects, share the same string instead of
+// allocating a new one each time. Its size is bounded and it is kept
+// between documents when the parser is reused.
+type internTable map[string]string
+
+// intern returns a string equal to s that can be kept after the call. s may
+// share memory with a tokenizer buffer, looking it up does not allocate
+// memory and only new keys are copied.
+func (t *internTable) intern(s string) string {
+	if v, ok := (*t)[s]; ok {
+		return v
+	}
+
+	s = strings.Clone(s)
+	if len(s) > maxInternedLength || len(*t) >= maxInterned {
+		return s
+	}
+
+	if *t == nil {
+		*t = make(internTable)
+	}
+	(*t)[s] = s
+
+	return s
+}
+
+// isKey returns true when the next string token is an object key.
+func (p *commonParser) isKey() bool {
+	s := p.lastState()
+	return s.jsonType == TypeObject && !s.hasKey
+}
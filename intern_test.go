@@ -0,0 +1,92 @@
+package jsonflatten
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"unsafe"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInternTable(t *testing.T) {
+	var table internTable
+
+	buf := []byte("key")
+	a := table.intern(unsafeString(buf))
+	copy(buf, "xxx")
+	b := table.intern("key")
+	require.Equal(t, "key", a)
+	require.Equal(t, unsafe.StringData(a), unsafe.StringData(b))
+
+	long := strings.Repeat("x", maxInternedLength+1)
+	require.Equal(t, long, table.intern(long))
+	require.Len(t, table, 1)
+
+	for i := range maxInterned * 2 {
+		table.intern(strconv.Itoa(i))
+	}
+	require.Len(t, table, maxInterned)
+}
+
+func TestInternEscapedKeys(t *testing.T) {
+	doc := `{"ab": 1, "\"q\"": [{"ab": 2}, {"ab": 3}], "é": 4}`
+	for name, f := range flatteners {
+		t.Run(name, func(t *testing.T) {
+			res, err := collect(t, f, doc)
+			require.NoError(t, err)
+			require.Equal(t, []pair{
+				{`"q".0.ab`, 2.0},
+				{`"q".1.ab`, 3.0},
+				{"ab", 1.0},
+				{"é", 4.0},
+			}, res)
+		})
+	}
+}
+
+func TestInternAllocations(t *testing.T) {
+	doc := func(n int) string {
+		var b strings.Builder
+		b.WriteString(`[`)
+		for i := range n {
+			if i > 0 {
+				b.WriteString(",")
+			}
+			b.WriteString(`{"id": 12345, "score": 1.5, "ok": true}`)
+		}
+		b.WriteString(`]`)
+		return b.String()
+	}
+
+	var sum float64
+	emitter := WithRawEmitter(func(k []byte, v Value) bool {
+		sum += v.Num
+		return true
+	})
+
+	parsers := map[string]interface {
+		flattener
+		Reset(Emitter)
+	}{
+		"v2":   NewParserV2(nil, emitter),
+		"pitr": NewParserPitr(nil, emitter),
+	}
+
+	for name, p := range parsers {
+		t.Run(name, func(t *testing.T) {
+			allocs := func(doc string) float64 {
+				r := strings.NewReader(doc)
+				return testing.AllocsPerRun(10, func() {
+					r.Reset(doc)
+					p.Reset(nil)
+					err := p.Parse(r)
+					require.NoError(t, err)
+				})
+			}
+
+			// repeated keys are not allocated for each object
+			require.Equal(t, allocs(doc(10)), allocs(doc(1000)))
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package jsonflatten
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// KeyTransform modifies each object key before it is added to the
+// flattened path.
+type KeyTransform func(string) string
+
+// maxKeyCache is the maximum number of transformed keys kept in the cache.
+const maxKeyCache = 4096
+
+// WithKeyTransform sets a function that modifies each object key. Array
+// indexes are not transformed.
+func WithKeyTransform(f KeyTransform) Option {
+	return func(o *options) {
+		o.keyTransform = f
+	}
+}
+
+// KeyLower converts the key to lower case.
+func KeyLower(k string) string {
+	return strings.ToLower(k)
+}
+
+// KeySnakeCase converts the key to lower case words separated by "_". For
+// example "GlossSeeAlso" becomes "gloss_see_also".
+func KeySnakeCase(k string) string {
+	words := splitWords(k)
+	for i, w := range words {
+		words[i] = strings.ToLower(w)
+	}
+
+	return strings.Join(words, "_")
+}
+
+// KeyCamelCase converts the key to camel case starting with lower case. For
+// example "gloss_see_also" becomes "glossSeeAlso".
+func KeyCamelCase(k string) string {
+	words := splitWords(k)
+
+	var b strings.Builder
+	b.Grow(len(k))
+	for i, w := range words {
+		w = strings.ToLower(w)
+		if i == 0 {
+			b.WriteString(w)
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(w)
+		b.WriteRune(unicode.ToUpper(r))
+		b.WriteString(w[size:])
+	}
+
+	return b.String()
+}
+
+// splitWords splits a key in words on any character that is not a letter or
+// digit and on case changes. Runs of upper case letters are kept as a single
+// word, "HTTPServer" is split in "HTTP" and "Server".
+func splitWords(s string) []string {
+	var words []string
+	runes := []rune(s)
+
+	start := -1
+	for i, r := range runes {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			if start >= 0 {
+				words = append(words, string(runes[start:i]))
+				start = -1
+			}
+			continue
+		}
+
+		if start < 0 {
+			start = i
+			continue
+		}
+
+		prev := runes[i-1]
+		if unicode.IsUpper(r) {
+			lowerBefore := unicode.IsLower(prev) || unicode.IsDigit(prev)
+			acronymEnd := unicode.IsUpper(prev) &&
+				i+1 < len(runes) && unicode.IsLower(runes[i+1])
+
+			if lowerBefore || acronymEnd {
+				words = append(words, string(runes[start:i]))
+				start = i
+			}
+		}
+	}
+
+	if start >= 0 {
+		words = append(words, string(runes[start:]))
+	}
+
+	return words
+}
+
+// transformKey applies the key transform caching the results so repeated
+// keys are not transformed again.
+func (p *commonParser) transformKey(k string) string {
+	f := p.options.keyTransform
+	if f == nil {
+		return k
+	}
+
+	if v, ok := p.keyCache[k]; ok {
+		return v
+	}
+
+	v := f(k)
+	if p.keyCache == nil {
+		p.keyCache = make(map[string]string)
+	}
+	if len(p.keyCache) < maxKeyCache {
+		p.keyCache[k] = v
+	}
+
+	return v
+}
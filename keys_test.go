@@ -0,0 +1,61 @@
+package jsonflatten
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyCase(t *testing.T) {
+	tests := []struct {
+		key   string
+		snake string
+		camel string
+	}{
+		{"GlossSeeAlso", "gloss_see_also", "glossSeeAlso"},
+		{"gloss_see_also", "gloss_see_also", "glossSeeAlso"},
+		{"ID", "id", "id"},
+		{"HTTPServer", "http_server", "httpServer"},
+		{"float64", "float64", "float64"},
+		{"some-key name", "some_key_name", "someKeyName"},
+		{"", "", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.key, func(t *testing.T) {
+			require.Equal(t, test.snake, KeySnakeCase(test.key))
+			require.Equal(t, test.camel, KeyCamelCase(test.key))
+		})
+	}
+}
+
+func TestKeyTransform(t *testing.T) {
+	doc := `{"GlossDiv": {"GlossSeeAlso": ["GML"], "list": [{"SortAs": 1}, {"SortAs": 2}]}}`
+	expected := []pair{
+		{"gloss_div.gloss_see_also.0", "GML"},
+		{"gloss_div.list.0.sort_as", float64(1)},
+		{"gloss_div.list.1.sort_as", float64(2)},
+	}
+
+	for name, f := range flatteners {
+		t.Run(name, func(t *testing.T) {
+			pairs, err := collect(t, f, doc, WithKeyTransform(KeySnakeCase))
+			require.NoError(t, err)
+			require.Equal(t, expected, pairs)
+		})
+	}
+}
+
+func TestKeyTransformCache(t *testing.T) {
+	calls := 0
+	transform := func(k string) string {
+		calls++
+		return KeyLower(k)
+	}
+
+	doc := `[{"A": 1}, {"A": 2}, {"A": 3}]`
+	pairs, err := collect(t, flatteners["pitr"], doc, WithKeyTransform(transform))
+	require.NoError(t, err)
+	require.Equal(t, []pair{{"0.a", float64(1)}, {"1.a", float64(2)}, {"2.a", float64(3)}}, pairs)
+	require.Equal(t, 1, calls)
+}
@@ -0,0 +1,234 @@
+package jsonflatten
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// WithLenient accepts JSONC and JSON5 style documents: "//" and "/* */"
+// comments, trailing commas in objects and arrays and single quoted
+// strings. The input is converted to standard JSON before it gets to the
+// tokenizer so it works with all the flatteners.
+func WithLenient(lenient bool) Option {
+	return func(o *options) {
+		o.lenient = lenient
+	}
+}
+
+const lenientChunk = 4 * 1024
+
+const (
+	lenientNormal = iota
+	lenientDouble
+	lenientSingle
+)
+
+// lenientReader converts JSONC and JSON5 syntax to standard JSON.
+type lenientReader struct {
+	r     *bufio.Reader
+	buf   []byte
+	pos   int
+	state int
+	comma bool
+	last  byte // last byte written outside of strings and whitespace
+	err   error
+}
+
+func newLenientReader(r io.Reader) *lenientReader {
+	return &lenientReader{
+		r:   bufio.NewReaderSize(r, lenientChunk),
+		buf: make([]byte, 0, lenientChunk),
+	}
+}
+
+// reset reuses the buffers to read from r.
+func (l *lenientReader) reset(r io.Reader) {
+	l.r.Reset(r)
+	*l = lenientReader{
+		r:   l.r,
+		buf: l.buf[:0],
+	}
+}
+
+func (l *lenientReader) Read(b []byte) (int, error) {
+	for l.pos == len(l.buf) {
+		if l.err != nil {
+			return 0, l.err
+		}
+
+		l.buf = l.buf[:0]
+		l.pos = 0
+		l.fill()
+	}
+
+	n := copy(b, l.buf[l.pos:])
+	l.pos += n
+
+	return n, nil
+}
+
+func (l *lenientReader) fill() {
+	for len(l.buf) < lenientChunk {
+		c, err := l.r.ReadByte()
+		if err != nil {
+			if l.comma {
+				l.buf = append(l.buf, ',')
+				l.comma = false
+			}
+			l.err = err
+			return
+		}
+
+		err = l.process(c)
+		if err != nil {
+			l.err = err
+			return
+		}
+	}
+}
+
+func (l *lenientReader) process(c byte) error {
+	switch l.state {
+	case lenientDouble:
+		switch c {
+		case '\\':
+			n, err := l.r.ReadByte()
+			if err != nil {
+				return unexpectedEOF(err)
+			}
+			if n == '\'' {
+				l.buf = append(l.buf, n)
+			} else {
+				l.buf = append(l.buf, c, n)
+			}
+		case '"':
+			l.buf = append(l.buf, c)
+			l.state = lenientNormal
+		default:
+			l.buf = append(l.buf, c)
+		}
+
+		return nil
+
+	case lenientSingle:
+		switch c {
+		case '\\':
+			n, err := l.r.ReadByte()
+			if err != nil {
+				return unexpectedEOF(err)
+			}
+			if n == '\'' {
+				l.buf = append(l.buf, n)
+			} else {
+				l.buf = append(l.buf, c, n)
+			}
+		case '"':
+			l.buf = append(l.buf, '\\', '"')
+		case '\'':
+			l.buf = append(l.buf, '"')
+			l.state = lenientNormal
+		default:
+			l.buf = append(l.buf, c)
+		}
+
+		return nil
+	}
+
+	switch c {
+	case ' ', '\t', '\r', '\n':
+		// whitespace after a comma is dropped until we know if it is a
+		// trailing comma
+		if !l.comma {
+			l.buf = append(l.buf, c)
+		}
+		return nil
+
+	case '/':
+		return l.comment()
+
+	case ',':
+		if l.comma {
+			// two commas in a row are invalid, let the tokenizer fail
+			l.buf = append(l.buf, ',')
+		}
+		l.comma = true
+		return nil
+
+	case ']', '}':
+		if l.comma && (l.last == '[' || l.last == '{') {
+			return fmt.Errorf("%w: comma without value before %q", ErrSyntax, c)
+		}
+		l.comma = false
+
+	default:
+		if l.comma {
+			l.buf = append(l.buf, ',')
+			l.comma = false
+		}
+	}
+
+	switch c {
+	case '"':
+		l.state = lenientDouble
+	case '\'':
+		l.state = lenientSingle
+		c = '"'
+	}
+
+	l.buf = append(l.buf, c)
+	l.last = c
+
+	return nil
+}
+
+func (l *lenientReader) comment() error {
+	n, err := l.r.ReadByte()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			l.buf = append(l.buf, '/')
+			return nil
+		}
+		return err
+	}
+
+	switch n {
+	case '/':
+		for {
+			c, err := l.r.ReadByte()
+			if err != nil {
+				return err
+			}
+			if c == '\n' {
+				return l.process(c)
+			}
+		}
+
+	case '*':
+		var last byte
+		for {
+			c, err := l.r.ReadByte()
+			if err != nil {
+				return unexpectedEOF(err)
+			}
+			if last == '*' && c == '/' {
+				return l.process(' ')
+			}
+			last = c
+		}
+
+	default:
+		// not a comment, let the tokenizer fail
+		l.buf = append(l.buf, '/')
+		return l.process(n)
+	}
+}
+
+func unexpectedEOF(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+
+	return err
+}
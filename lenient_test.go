@@ -0,0 +1,79 @@
+package jsonflatten
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const lenientJson = `// tsconfig style file
+{
+	/* compiler options */
+	"compilerOptions": {
+		"target": "es2020", // trailing comment
+		'module': 'it\'s "quoted"',
+		"paths": ["a/*", "b//c",],
+	},
+	"url": "http://example.com/*x*/",
+	"escaped": "don\'t",
+}
+// end`
+
+func TestLenient(t *testing.T) {
+	expected := []pair{
+		{"compilerOptions.module", `it's "quoted"`},
+		{"compilerOptions.paths.0", "a/*"},
+		{"compilerOptions.paths.1", "b//c"},
+		{"compilerOptions.target", "es2020"},
+		{"escaped", "don't"},
+		{"url", "http://example.com/*x*/"},
+	}
+
+	for name, f := range flatteners {
+		t.Run(name, func(t *testing.T) {
+			_, err := collect(t, f, lenientJson)
+			require.Error(t, err)
+
+			pairs, err := collect(t, f, lenientJson, WithLenient(true))
+			require.NoError(t, err)
+			require.Equal(t, expected, pairs)
+		})
+	}
+}
+
+func TestLenientReader(t *testing.T) {
+	tests := []struct {
+		in       string
+		expected string
+	}{
+		{`[1, 2, ]`, `[1,2]`},
+		{`[1,, 2]`, `[1,,2]`},
+		{`{"a": 1 /* x */ , }`, `{"a": 1   }`},
+		{`'a"b'`, `"a\"b"`},
+		{`1 / 2`, `1 / 2`},
+		{`[1, // x` + "\n" + `2]`, `[1,2]`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.in, func(t *testing.T) {
+			b, err := io.ReadAll(newLenientReader(strings.NewReader(test.in)))
+			require.NoError(t, err)
+			require.Equal(t, test.expected, string(b))
+		})
+	}
+
+	_, err := io.ReadAll(newLenientReader(strings.NewReader(`[1 /* x`)))
+	require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+
+	for _, in := range []string{`[,]`, `{,}`, `[ , ]`, `{"a": [ /* x */ , ]}`} {
+		_, err := io.ReadAll(newLenientReader(strings.NewReader(in)))
+		require.ErrorIs(t, err, ErrSyntax, in)
+
+		for name, f := range flatteners {
+			_, err := collect(t, f, in, WithLenient(true))
+			require.ErrorIs(t, err, ErrSyntax, "%s: %s", name, in)
+		}
+	}
+}
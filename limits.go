@@ -0,0 +1,147 @@
+package jsonflatten
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+var (
+	// ErrMaxDepth is returned when objects and arrays are nested deeper than
+	// the configured limit.
+	ErrMaxDepth = errors.New("maximum depth exceeded")
+	// ErrMaxValues is returned when the document has more values than the
+	// configured limit.
+	ErrMaxValues = errors.New("maximum number of values exceeded")
+	// ErrMaxKeyLength is returned when an object key is longer than the
+	// configured limit.
+	ErrMaxKeyLength = errors.New("maximum key length exceeded")
+	// ErrMaxStringLength is returned when a string value is longer than the
+	// configured limit.
+	ErrMaxStringLength = errors.New("maximum string length exceeded")
+	// ErrMaxInputSize is returned when the input is bigger than the
+	// configured limit.
+	ErrMaxInputSize = errors.New("maximum input size exceeded")
+)
+
+type limits struct {
+	depth        int
+	values       int
+	keyLength    int
+	stringLength int
+	inputSize    int64
+}
+
+// WithMaxDepth limits how deep objects and arrays can be nested. Zero means
+// no limit.
+func WithMaxDepth(n int) Option {
+	return func(o *options) {
+		o.limits.depth = n
+	}
+}
+
+// WithMaxValues limits the number of scalar values in the document. Zero
+// means no limit.
+func WithMaxValues(n int) Option {
+	return func(o *options) {
+		o.limits.values = n
+	}
+}
+
+// WithMaxKeyLength limits the length in bytes of object keys. Zero means no
+// limit.
+func WithMaxKeyLength(n int) Option {
+	return func(o *options) {
+		o.limits.keyLength = n
+	}
+}
+
+// WithMaxStringLength limits the length in bytes of string values. Zero
+// means no limit.
+func WithMaxStringLength(n int) Option {
+	return func(o *options) {
+		o.limits.stringLength = n
+	}
+}
+
+// WithMaxInputSize limits the number of bytes read from the input. Zero
+// means no limit.
+func WithMaxInputSize(n int64) Option {
+	return func(o *options) {
+		o.limits.inputSize = n
+	}
+}
+
+func (p *commonParser) checkDepth() error {
+	limit := p.options.limits.depth
+	if limit > 0 && len(p.States) >= limit {
+		return fmt.Errorf("%w: %d", ErrMaxDepth, limit)
+	}
+
+	return nil
+}
+
+func (p *commonParser) checkValues() error {
+	p.values++
+
+	limit := p.options.limits.values
+	if limit > 0 && p.values > limit {
+		return fmt.Errorf("%w: %d", ErrMaxValues, limit)
+	}
+
+	return nil
+}
+
+func (p *commonParser) checkKey(k string) error {
+	limit := p.options.limits.keyLength
+	if limit > 0 && len(k) > limit {
+		return fmt.Errorf("%w: %d", ErrMaxKeyLength, limit)
+	}
+
+	return nil
+}
+
+func (p *commonParser) checkString(v string) error {
+	limit := p.options.limits.stringLength
+	if limit > 0 && len(v) > limit {
+		return fmt.Errorf("%w: %d", ErrMaxStringLength, limit)
+	}
+
+	return nil
+}
+
+// limitInput wraps the reader to fail when it reads more bytes than the
+// configured limit.
+func (p *commonParser) limitInput(r io.Reader) io.Reader {
+	limit := p.options.limits.inputSize
+	if limit <= 0 {
+		return r
+	}
+
+	p.limit = limitReader{r: r, n: limit}
+	return &p.limit
+}
+
+type limitReader struct {
+	r io.Reader
+	n int64
+}
+
+func (l *limitReader) Read(b []byte) (int, error) {
+	if l.n < 0 {
+		return 0, ErrMaxInputSize
+	}
+
+	// read one more byte than allowed to detect inputs over the limit
+	if int64(len(b)) > l.n+1 {
+		b = b[:l.n+1]
+	}
+
+	n, err := l.r.Read(b)
+	l.n -= int64(n)
+	if l.n < 0 {
+		return 0, ErrMaxInputSize
+	}
+
+	return n, err
+}
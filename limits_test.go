@@ -0,0 +1,116 @@
+package jsonflatten
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimits(t *testing.T) {
+	tests := []struct {
+		name     string
+		doc      string
+		opt      Option
+		expected error
+	}{
+		{
+			name:     "depth",
+			doc:      `{"a": {"b": {"c": 1}}}`,
+			opt:      WithMaxDepth(2),
+			expected: ErrMaxDepth,
+		},
+		{
+			name:     "values",
+			doc:      `[1, 2, 3, 4]`,
+			opt:      WithMaxValues(3),
+			expected: ErrMaxValues,
+		},
+		{
+			name:     "key length",
+			doc:      `{"short": 1, "very long key": 2}`,
+			opt:      WithMaxKeyLength(5),
+			expected: ErrMaxKeyLength,
+		},
+		{
+			name:     "string length",
+			doc:      `{"a": "short", "b": "very long value"}`,
+			opt:      WithMaxStringLength(5),
+			expected: ErrMaxStringLength,
+		},
+		{
+			name:     "input size",
+			doc:      `{"a": "` + strings.Repeat("x", 10000) + `"}`,
+			opt:      WithMaxInputSize(100),
+			expected: ErrMaxInputSize,
+		},
+	}
+
+	for _, test := range tests {
+		for name, f := range flatteners {
+			t.Run(test.name+"/"+name, func(t *testing.T) {
+				_, err := collect(t, f, test.doc)
+				require.NoError(t, err)
+
+				_, err = collect(t, f, test.doc, test.opt)
+				require.ErrorIs(t, err, test.expected)
+				require.NotErrorIs(t, err, ErrSyntax)
+			})
+		}
+	}
+}
+
+func TestLimitsNotReached(t *testing.T) {
+	for name, f := range flatteners {
+		t.Run(name, func(t *testing.T) {
+			_, err := collect(t, f, testJson,
+				WithMaxDepth(7),
+				WithMaxValues(24),
+				WithMaxKeyLength(12),
+				WithMaxStringLength(74),
+				WithMaxInputSize(int64(len(testJson))),
+			)
+			require.NoError(t, err)
+		})
+	}
+}
+
+// endlessString is a document with a string value that never ends.
+type endlessString struct {
+	read int
+}
+
+func (e *endlessString) Read(b []byte) (int, error) {
+	n := copy(b, `{"key": "`)
+	if e.read > 0 {
+		n = 0
+	}
+	for i := n; i < len(b); i++ {
+		b[i] = 'x'
+	}
+
+	e.read += len(b)
+	return len(b), nil
+}
+
+func TestLimitsWhileScanning(t *testing.T) {
+	// ParserFast stops reading a string as soon as it is over the limit
+	r := &endlessString{}
+	p := NewParserFast(nil, WithMaxStringLength(1<<20))
+	err := p.Parse(r)
+	require.ErrorIs(t, err, ErrMaxStringLength)
+	require.Less(t, r.read, 4<<20)
+
+	// escaped strings are checked after decoding them
+	doc := `{"a": "\u0041\u0041\u0041\u0041"}`
+	var values []any
+	p = NewParserFast(func(k string, v any) bool {
+		values = append(values, v)
+		return true
+	}, WithMaxStringLength(4), WithMaxKeyLength(1))
+	require.NoError(t, p.Parse(strings.NewReader(doc)))
+	require.Equal(t, []any{"AAAA"}, values)
+
+	err = p.Parse(strings.NewReader(`{"long key": 1}`))
+	require.ErrorIs(t, err, ErrMaxKeyLength)
+}
@@ -14,3 +14,24 @@ func TestMemory(t *testing.T) {
 	err := m.Parse(r)
 	require.NoError(t, err)
 }
+
+func TestMemoryDepth(t *testing.T) {
+	deep := strings.Repeat("[", 5_000_000)
+
+	parsers := map[string]func(...Option) flattener{
+		"memory":   func(o ...Option) flattener { return NewMemory(nil, o...) },
+		"memoryv2": func(o ...Option) flattener { return NewMemoryV2(nil, o...) },
+	}
+
+	for name, f := range parsers {
+		t.Run(name, func(t *testing.T) {
+			// the limit is checked while the document is decoded
+			err := f(WithMaxDepth(10)).Parse(strings.NewReader(deep))
+			require.ErrorIs(t, err, ErrMaxDepth)
+
+			// without limit deep documents do not overflow the stack
+			err = f().Parse(strings.NewReader(deep[:100_000]))
+			require.ErrorIs(t, err, ErrTruncated)
+		})
+	}
+}
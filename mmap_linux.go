@@ -0,0 +1,40 @@
+//go:build linux
+
+package jsonflatten
+
+import (
+	"os"
+	"syscall"
+)
+
+// mapFile maps the file at path in memory. The returned function unmaps it.
+// Files that can not be mapped, like pipes, are read instead.
+//
+// The mapping is private and read only, but it is not a copy: if another
+// process truncates the file while it is parsed, reading the missing pages
+// raises SIGBUS and the program crashes.
+func mapFile(path string) ([]byte, func(), error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	st, err := f.Stat()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	size := st.Size()
+	if !st.Mode().IsRegular() || size == 0 || int64(int(size)) != size {
+		return readFile(path)
+	}
+
+	b, err := syscall.Mmap(int(f.Fd()), 0, int(size),
+		syscall.PROT_READ, syscall.MAP_PRIVATE)
+	if err != nil {
+		return readFile(path)
+	}
+
+	return b, func() { _ = syscall.Munmap(b) }, nil
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+
+package jsonflatten
+
+// mapFile reads the file at path. Memory mapping is only used on Linux.
+func mapFile(path string) ([]byte, func(), error) {
+	return readFile(path)
+}
@@ -0,0 +1,291 @@
+package jsonflatten
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"slices"
+	"sync"
+	"sync/atomic"
+)
+
+// RecordEmitter is called for each value of a NDJSON record with the line
+// number of the record, starting at 1. It returns false to stop parsing.
+type RecordEmitter func(line int, key string, value any) bool
+
+// RecordError is the error of a NDJSON record.
+type RecordError struct {
+	Line int
+	Err  error
+}
+
+func (e *RecordError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *RecordError) Unwrap() error {
+	return e.Err
+}
+
+// WithConcurrentEmitter makes NDJSON call the RecordEmitter directly from
+// its workers as soon as each record is flattened. The emitter must be safe
+// for concurrent use. By default the values are emitted in order from the
+// goroutine that calls Parse. It is ignored by other flatteners.
+func WithConcurrentEmitter(concurrent bool) Option {
+	return func(o *options) {
+		o.concurrent = concurrent
+	}
+}
+
+// NDJSON flattens newline delimited json, one document per line, using
+// several goroutines. Each worker owns a ParserPitr that is reused for its
+// records. The number of workers is set with WithWorkers.
+//
+// Invalid records do not stop the parse, their errors are returned joined
+// as RecordError when all the input is read. Nothing is returned if the
+// emitter stops the parse. Limits other than WithMaxInputSize are applied
+// to each record.
+type NDJSON struct {
+	emitter RecordEmitter
+	options options
+	opts    []Option
+
+	pool   pool
+	reader *bufio.Reader
+	limit  limitReader
+	errs   []error
+	stop   atomic.Bool
+
+	// text writes the values when there is no emitter
+	text *Writer
+	mu   sync.Mutex
+}
+
+// NewNDJSON creates a new NDJSON flattener that calls emitter for each
+// value of each record. If emitter is nil the values are printed.
+func NewNDJSON(emitter RecordEmitter, opts ...Option) *NDJSON {
+	n := &NDJSON{
+		options: newOptions(opts),
+		opts:    opts,
+	}
+	n.Reset(emitter)
+
+	return n
+}
+
+// Reset prepares the flattener to parse a new input with a different
+// emitter. The workers and their buffers are kept. If emitter is nil the
+// values are printed.
+func (n *NDJSON) Reset(emitter RecordEmitter) {
+	n.emitter = emitter
+	if emitter == nil {
+		n.emitter = n.print
+	}
+}
+
+// print is the emitter used when none is provided. It writes the values to
+// the WithOutput writer like the other flatteners. The workers call it
+// concurrently with WithConcurrentEmitter.
+func (n *NDJSON) print(_ int, k string, v any) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.text == nil {
+		out := n.options.output
+		if out == nil {
+			out = os.Stdout
+		}
+		n.text = NewTextEmitter(out)
+	}
+
+	return n.text.Emit(k, v)
+}
+
+// Parse reads the records from r and calls the emitter for each value.
+// WithGron is not supported as the records are flattened concurrently.
+func (n *NDJSON) Parse(r io.Reader) error {
+	if n.options.gron != nil {
+		return fmt.Errorf("%w: gron with NDJSON", errors.ErrUnsupported)
+	}
+
+	n.errs = n.errs[:0]
+	n.stop.Store(false)
+
+	if size := n.options.limits.inputSize; size > 0 {
+		n.limit = limitReader{r: r, n: size}
+		r = &n.limit
+	}
+
+	if n.reader == nil {
+		n.reader = bufio.NewReaderSize(r, scanSize)
+	} else {
+		n.reader.Reset(r)
+	}
+
+	workers := n.options.workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	// each line is parsed on its own so the limits apply to the record
+	n.pool.start(workers, append(slices.Clip(n.opts), WithMaxInputSize(0)))
+	for _, w := range n.pool.workers {
+		w.direct = nil
+		if n.options.concurrent {
+			w.direct = n.direct
+		}
+	}
+
+	err := n.pool.run(n.scan, workLines, n.deliver, n.options.concurrent)
+	if ferr := n.flush(); ferr != nil {
+		return ferr
+	}
+	if errors.Is(err, errExit) {
+		return nil
+	}
+	if err != nil {
+		n.errs = append(n.errs, err)
+	}
+
+	return errors.Join(n.errs...)
+}
+
+// flush writes the values buffered by print.
+func (n *NDJSON) flush() error {
+	if n.text == nil {
+		return nil
+	}
+
+	return n.text.Flush()
+}
+
+// scan reads lines and sends them to the workers in chunks.
+func (n *NDJSON) scan(jobs chan<- *chunk, done <-chan struct{}) error {
+	var c *chunk
+	for seq, line := 0, 1; ; line++ {
+		if c == nil {
+			c = n.pool.get(done)
+			if c == nil {
+				return nil
+			}
+
+			c.seq = seq
+			c.offset = line
+			c.data = c.data[:0]
+			seq++
+		}
+
+		mark := len(c.data)
+
+		var err error
+		c.data, err = readLine(n.reader, c.data)
+		if err != nil {
+			// drop the incomplete line
+			c.data = c.data[:mark]
+			if len(c.data) > 0 {
+				jobs <- c
+			} else {
+				n.pool.free <- c
+			}
+
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+
+		if len(c.data) >= chunkSize {
+			jobs <- c
+			c = nil
+		}
+	}
+}
+
+// readLine appends the next line to dst ending it with a new line. It
+// returns io.EOF when there are no more lines.
+func readLine(r *bufio.Reader, dst []byte) ([]byte, error) {
+	start := len(dst)
+	for {
+		b, err := r.ReadSlice('\n')
+		dst = append(dst, b...)
+
+		switch {
+		case err == nil:
+			return dst, nil
+		case errors.Is(err, bufio.ErrBufferFull):
+			continue
+		case errors.Is(err, io.EOF) && len(dst) > start:
+			return append(dst, '\n'), nil
+		default:
+			return dst, err
+		}
+	}
+}
+
+// workLines flattens each line of a chunk as a separate document.
+func workLines(w *worker, c *chunk) {
+	c.reset()
+
+	data := c.data
+	for line := c.offset; len(data) > 0; line++ {
+		i := bytes.IndexByte(data, '\n')
+		record := data[:i]
+		data = data[i+1:]
+
+		if len(bytes.TrimSpace(record)) == 0 {
+			continue
+		}
+
+		w.record = line
+		if err := w.parse(c, record, 0); err != nil {
+			c.errs = append(c.errs, &RecordError{Line: line, Err: err})
+		}
+	}
+}
+
+// direct is the emitter used by the workers with WithConcurrentEmitter.
+func (n *NDJSON) direct(line int, key string, value any) bool {
+	if n.stop.Load() {
+		return false
+	}
+
+	if !n.emitter(line, key, value) {
+		n.stop.Store(true)
+		return false
+	}
+
+	return true
+}
+
+// deliver calls the emitter with the values of a chunk and keeps its
+// errors.
+func (n *NDJSON) deliver(c *chunk) error {
+	if n.options.concurrent {
+		n.errs = append(n.errs, c.errs...)
+		if n.stop.Load() {
+			return errExit
+		}
+		return nil
+	}
+
+	start := 0
+	for _, it := range c.items {
+		v := it.value
+		if v.Kind == KindString {
+			v.Str = string(c.buf[it.keyEnd:it.strEnd])
+		}
+
+		if !n.emitter(it.record, string(c.buf[start:it.keyEnd]), v.Any()) {
+			return errExit
+		}
+
+		start = it.strEnd
+	}
+
+	n.errs = append(n.errs, c.errs...)
+	return nil
+}
@@ -0,0 +1,229 @@
+package jsonflatten
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type record struct {
+	Line  int
+	Key   string
+	Value any
+}
+
+func ndjsonDoc(n int) (string, []record) {
+	var b strings.Builder
+	var expected []record
+	for i := range n {
+		line := i + 1
+		switch {
+		case line%100 == 0:
+			b.WriteString(`{"broken": ` + "\n")
+		case line%10 == 0:
+			b.WriteString("  \n")
+		default:
+			fmt.Fprintf(&b, `{"id": %d, "name": "n%d", "tags": [true]}`+"\n", i, i)
+			expected = append(expected,
+				record{line, "id", float64(i)},
+				record{line, "name", fmt.Sprintf("n%d", i)},
+				record{line, "tags.0", true},
+			)
+		}
+	}
+
+	return b.String(), expected
+}
+
+func recordErrors(t *testing.T, err error) []int {
+	t.Helper()
+
+	var lines []int
+	for _, err := range err.(interface{ Unwrap() []error }).Unwrap() {
+		var e *RecordError
+		require.ErrorAs(t, err, &e)
+		require.ErrorIs(t, err, ErrTruncated)
+		lines = append(lines, e.Line)
+	}
+
+	return lines
+}
+
+func TestNDJSON(t *testing.T) {
+	doc, expected := ndjsonDoc(3000)
+
+	var records []record
+	emitter := func(line int, k string, v any) bool {
+		records = append(records, record{line, k, v})
+		return true
+	}
+
+	p := NewNDJSON(emitter, WithWorkers(4))
+	for range 3 {
+		records = nil
+		p.Reset(emitter)
+		err := p.Parse(strings.NewReader(doc))
+		require.Equal(t, expected, records)
+
+		lines := recordErrors(t, err)
+		require.Len(t, lines, 30)
+		require.Equal(t, 100, lines[0])
+		require.True(t, slices.IsSorted(lines))
+	}
+}
+
+func TestNDJSONConcurrent(t *testing.T) {
+	doc, expected := ndjsonDoc(3000)
+
+	var m sync.Mutex
+	var records []record
+	p := NewNDJSON(func(line int, k string, v any) bool {
+		m.Lock()
+		defer m.Unlock()
+		records = append(records, record{line, k, v})
+		return true
+	}, WithWorkers(4), WithConcurrentEmitter(true))
+
+	err := p.Parse(strings.NewReader(doc))
+	require.Len(t, recordErrors(t, err), 30)
+
+	slices.SortStableFunc(records, func(a, b record) int {
+		return a.Line - b.Line
+	})
+	require.Equal(t, expected, records)
+}
+
+func TestNDJSONStop(t *testing.T) {
+	doc, _ := ndjsonDoc(3000)
+
+	for _, concurrent := range []bool{false, true} {
+		t.Run(fmt.Sprint("concurrent=", concurrent), func(t *testing.T) {
+			var m sync.Mutex
+			var count int
+			p := NewNDJSON(func(line int, k string, v any) bool {
+				m.Lock()
+				defer m.Unlock()
+				count++
+				return count < 50
+			}, WithConcurrentEmitter(concurrent))
+
+			err := p.Parse(strings.NewReader(doc))
+			require.NoError(t, err)
+			if concurrent {
+				// other workers can be in the middle of a record
+				require.GreaterOrEqual(t, count, 50)
+			} else {
+				require.Equal(t, 50, count)
+			}
+		})
+	}
+}
+
+func TestNDJSONLines(t *testing.T) {
+	long := strings.Repeat("x", scanSize*3)
+	doc := `{"a": "` + long + "\"}\r\n[1, 2]\n\n" + `{"b": null}`
+
+	var records []record
+	p := NewNDJSON(func(line int, k string, v any) bool {
+		records = append(records, record{line, k, v})
+		return true
+	})
+
+	err := p.Parse(strings.NewReader(doc))
+	require.NoError(t, err)
+	require.Equal(t, []record{
+		{1, "a", long},
+		{2, "0", 1.0},
+		{2, "1", 2.0},
+		{4, "b", nil},
+	}, records)
+
+	p = NewNDJSON(func(line int, k string, v any) bool { return true },
+		WithMaxInputSize(10))
+	err = p.Parse(strings.NewReader(doc))
+	require.ErrorIs(t, err, ErrMaxInputSize)
+	require.False(t, errors.As(err, new(*RecordError)))
+}
+
+// repeatReader returns line n times and counts the bytes read.
+type repeatReader struct {
+	line string
+	n    int
+	pos  int
+	read int
+}
+
+func (r *repeatReader) Read(b []byte) (int, error) {
+	total := 0
+	for len(b) > 0 && r.n > 0 {
+		c := copy(b, r.line[r.pos:])
+		b = b[c:]
+		total += c
+		r.pos += c
+		if r.pos == len(r.line) {
+			r.pos = 0
+			r.n--
+		}
+	}
+
+	r.read += total
+	if total == 0 {
+		return 0, io.EOF
+	}
+
+	return total, nil
+}
+
+func TestNDJSONStopReading(t *testing.T) {
+	line := `{"id": 1, "name": "record"}` + "\n"
+	r := &repeatReader{line: line, n: 100 * chunkSize / len(line)}
+
+	p := NewNDJSON(func(line int, k string, v any) bool {
+		return false
+	}, WithWorkers(2))
+
+	err := p.Parse(r)
+	require.NoError(t, err)
+
+	// the chunks in flight and the read buffer, not the whole input
+	require.Less(t, r.read, (cap(p.pool.free)+2)*chunkSize)
+}
+
+func TestNDJSONPrint(t *testing.T) {
+	doc := `{"a": 1}` + "\n" + `{"b": "x"}` + "\n"
+
+	var buf bytes.Buffer
+	p := NewNDJSON(nil, WithOutput(&buf))
+	require.NoError(t, p.Parse(strings.NewReader(doc)))
+	require.Equal(t, "a = 1\nb = \"x\"\n", buf.String())
+
+	buf.Reset()
+	p = NewNDJSON(func(int, string, any) bool { return true },
+		WithOutput(&buf), WithConcurrentEmitter(true))
+	p.Reset(nil)
+	require.NoError(t, p.Parse(strings.NewReader(doc)))
+	// the workers print the records in any order
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	require.ElementsMatch(t, []string{"a = 1", `b = "x"`}, lines)
+}
+
+func TestPoolGetDone(t *testing.T) {
+	var p pool
+	p.start(1, nil)
+
+	done := make(chan struct{})
+	require.NotNil(t, p.get(done))
+
+	// free chunks are not returned after done is closed
+	close(done)
+	for range 100 {
+		require.Nil(t, p.get(done))
+	}
+}
@@ -0,0 +1,232 @@
+package jsonflatten
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// node is a decoded json value that keeps the order of the object members
+// and repeated keys, unlike decoding to map[string]any.
+type node struct {
+	value any
+	// open is set for the objects and arrays that were not closed when
+	// the input ended
+	open bool
+}
+
+type member struct {
+	key   string
+	value node
+}
+
+type object []member
+type array []node
+
+// decodeNode reads the next value from the decoder. Object keys are
+// interned in keys so repeated keys share memory. The input ending inside
+// an object or array returns io.ErrUnexpectedEOF with the part of the
+// document that was read, so the flattener can report the open path. The
+// open containers are kept in a stack instead of recursing so deep
+// documents do not overflow the goroutine stack, and nesting deeper than
+// maxDepth fails with ErrMaxDepth before the rest of the document is
+// decoded.
+func decodeNode(dec *json.Decoder, keys *internTable, maxDepth int) (node, error) {
+	var stack []container
+	for {
+		t, err := dec.Token()
+		if err != nil {
+			err = syntaxError(err)
+			if len(stack) == 0 {
+				return node{}, err
+			}
+
+			err = unexpectedEOF(err)
+			if errors.Is(err, io.ErrUnexpectedEOF) {
+				return partialNode(stack), err
+			}
+			return node{}, err
+		}
+
+		var n node
+		if d, ok := t.(json.Delim); ok {
+			switch d {
+			case '{', '[':
+				if maxDepth > 0 && len(stack) >= maxDepth {
+					return node{}, fmt.Errorf("%w: %d", ErrMaxDepth, maxDepth)
+				}
+
+				stack = append(stack, container{
+					isObj:  d == '{',
+					object: object{},
+					array:  array{},
+				})
+				continue
+
+			default:
+				c := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+
+				n = node{value: c.array}
+				if c.isObj {
+					n = node{value: c.object}
+				}
+			}
+		} else {
+			n = node{value: t}
+		}
+
+		if len(stack) == 0 {
+			return n, nil
+		}
+
+		c := &stack[len(stack)-1]
+		switch {
+		case !c.isObj:
+			c.array = append(c.array, n)
+
+		case !c.hasKey:
+			key, ok := t.(string)
+			if !ok {
+				return node{}, fmt.Errorf("%w: invalid key %v", ErrSyntax, t)
+			}
+			c.key = keys.intern(key)
+			c.hasKey = true
+
+		default:
+			c.object = append(c.object, member{key: c.key, value: n})
+			c.hasKey = false
+		}
+	}
+}
+
+// partialNode returns the document of the containers left open in the stack
+// of decodeNode.
+func partialNode(stack []container) node {
+	var n node
+	for i := len(stack) - 1; i >= 0; i-- {
+		c := &stack[i]
+		if n.value != nil {
+			if c.isObj {
+				c.object = append(c.object, member{key: c.key, value: n})
+			} else {
+				c.array = append(c.array, n)
+			}
+		}
+
+		n = node{value: c.array, open: true}
+		if c.isObj {
+			n = node{value: c.object, open: true}
+		}
+	}
+
+	return n
+}
+
+// container is an object or array being decoded by decodeNode.
+type container struct {
+	isObj  bool
+	object object
+	array  array
+	// key is the key of the next member when hasKey is set
+	key    string
+	hasKey bool
+}
+
+// nodeTokenizer decodes each document to nodes and returns their tokens
+// walking the tree.
+type nodeTokenizer struct {
+	dec      *json.Decoder
+	keys     *internTable
+	maxDepth int
+	stack    []frame
+	// err is returned at the end of a document that was not complete
+	err error
+}
+
+// frame is an object or array being walked.
+type frame struct {
+	object object
+	array  array
+	isObj  bool
+	open   bool
+	i      int
+	// key is set when the key of the current member was returned
+	key bool
+}
+
+func (t *nodeTokenizer) reset(dec *json.Decoder, keys *internTable, maxDepth int) {
+	t.dec = dec
+	t.keys = keys
+	t.maxDepth = maxDepth
+	t.err = nil
+	clear(t.stack)
+	t.stack = t.stack[:0]
+}
+
+func (t *nodeTokenizer) next() (token, error) {
+	if len(t.stack) == 0 {
+		n, err := decodeNode(t.dec, t.keys, t.maxDepth)
+		if err != nil && n.value == nil {
+			return token{}, err
+		}
+
+		t.err = err
+		return t.enter(n)
+	}
+
+	f := &t.stack[len(t.stack)-1]
+	if f.isObj {
+		if f.i == len(f.object) {
+			if f.open {
+				return token{}, t.err
+			}
+			t.stack = t.stack[:len(t.stack)-1]
+			return objectEnd, nil
+		}
+
+		m := f.object[f.i]
+		if !f.key {
+			f.key = true
+			return valueToken(stringValue(m.key)), nil
+		}
+
+		f.key = false
+		f.i++
+		return t.enter(m.value)
+	}
+
+	if f.i == len(f.array) {
+		if f.open {
+			return token{}, t.err
+		}
+		t.stack = t.stack[:len(t.stack)-1]
+		return arrayEnd, nil
+	}
+
+	f.i++
+	return t.enter(f.array[f.i-1])
+}
+
+// enter returns the first token of n.
+func (t *nodeTokenizer) enter(n node) (token, error) {
+	switch v := n.value.(type) {
+	case object:
+		t.stack = append(t.stack, frame{object: v, isObj: true, open: n.open})
+		return objectStart, nil
+	case array:
+		t.stack = append(t.stack, frame{array: v, open: n.open})
+		return arrayStart, nil
+	case string:
+		return valueToken(stringValue(v)), nil
+	case float64:
+		return valueToken(numberValue(v)), nil
+	case bool:
+		return valueToken(boolValue(v)), nil
+	case nil:
+		return valueToken(nullValue), nil
+	default:
+		return token{}, fmt.Errorf("%w: invalid type %+v", ErrSyntax, v)
+	}
+}
@@ -0,0 +1,43 @@
+package jsonflatten
+
+import "io"
+
+// Option changes the default behavior of a flattener.
+type Option func(*options)
+
+type options struct {
+	arrayMode      ArrayMode
+	arraySeparator string
+	separator      string
+	keyTransform   KeyTransform
+	limits         limits
+	duplicates     DuplicateMode
+	lenient        bool
+	utf8           UTF8Mode
+	strict         bool
+	rawEmitter     RawEmitter
+	typedEmitter   TypedEmitter
+	workers        int
+	unordered      bool
+	concurrent     bool
+	output         io.Writer
+	gron           *Gron
+}
+
+func newOptions(opts []Option) options {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if o.arraySeparator == "" {
+		o.arraySeparator = ","
+	}
+
+	// gron statements have a key for each element
+	if o.gron != nil {
+		o.arrayMode = ArrayIndex
+	}
+
+	return o
+}
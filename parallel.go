@@ -0,0 +1,406 @@
+package jsonflatten
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"slices"
+)
+
+const (
+	// chunkSize is the minimum size in bytes of the array elements sent
+	// together to a worker.
+	chunkSize = 128 * 1024
+	// scanSize is the size of the buffer used to scan the input.
+	scanSize = 64 * 1024
+)
+
+// WithWorkers sets the number of goroutines used by Parallel. Zero uses
+// runtime.GOMAXPROCS. It is ignored by other flatteners.
+func WithWorkers(n int) Option {
+	return func(o *options) {
+		o.workers = n
+	}
+}
+
+// WithUnordered makes Parallel call the emitter with the values of each
+// chunk of the array as soon as they are flattened instead of in document
+// order. The keys keep the index of the elements in the whole array. It is
+// ignored by other flatteners.
+func WithUnordered(unordered bool) Option {
+	return func(o *options) {
+		o.unordered = unordered
+	}
+}
+
+// Parallel flattens documents whose root is an array using several
+// goroutines. The array is split in chunks of elements that are flattened
+// by a pool of ParserPitr workers. The emitter is always called from the
+// goroutine that calls Parse. Documents with other root values are
+// flattened by a single ParserPitr.
+//
+// Limits other than WithMaxValues and WithMaxInputSize are applied to each
+// chunk, so the errors are the same as with other flatteners.
+type Parallel struct {
+	commonParser
+
+	opts      []Option
+	scanner   scanner
+	pool      pool
+	seq       *ParserPitr
+	chunkSize int
+}
+
+// NewParallel creates a new flattener that splits root arrays between
+// several goroutines. If emitter is nil the values are printed.
+func NewParallel(emitter Emitter, opts ...Option) *Parallel {
+	return &Parallel{
+		commonParser: newCommonParser(emitter, opts),
+		opts:         opts,
+		chunkSize:    chunkSize,
+	}
+}
+
+// Parse json and call the provided emitter for each value.
+func (p *Parallel) Parse(r io.Reader) error {
+	p.scanner.reset(p.input(r))
+	return p.parse()
+}
+
+// parse flattens the documents read by the scanner and flushes the
+// printed values.
+func (p *Parallel) parse() error {
+	p.begin()
+	return p.flush(p.documents())
+}
+
+// documents flattens the documents read by the scanner.
+func (p *Parallel) documents() error {
+	s := &p.scanner
+
+	// several concatenated documents are flattened unless strict mode is
+	// enabled
+	for first := true; ; first = false {
+		c, ok := s.peek()
+		if !ok {
+			if err := s.error(); err != nil || !first || !p.options.strict {
+				return err
+			}
+			return fmt.Errorf("%w: empty document", ErrTruncated)
+		}
+
+		if !first && p.options.strict {
+			return trailingError(c)
+		}
+
+		// gron statements are sorted and written by a single goroutine
+		if c != '[' || p.options.gron != nil {
+			return p.sequential(s.rest())
+		}
+
+		err := p.parseArray()
+		if errors.Is(err, errExit) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// trailingError returns the strict mode error for the byte c found after the
+// root value. Like in the other flatteners a byte that does not start a
+// value is also a syntax error.
+func trailingError(c byte) error {
+	switch {
+	case c == '{', c == '[', c == '"', c == '-', c >= '0' && c <= '9',
+		c == 't', c == 'f', c == 'n':
+		return ErrTrailingData
+	}
+
+	return fmt.Errorf("%w: %w: invalid character %q after root array",
+		ErrTrailingData, ErrSyntax, c)
+}
+
+// sequential flattens the rest of the input with a single parser.
+func (p *Parallel) sequential(r io.Reader) error {
+	if p.seq == nil {
+		p.seq = NewParserPitr(p.emitter, p.opts...)
+	} else {
+		p.seq.Reset(p.emitter)
+	}
+
+	// the printed values share the buffer so they keep the document order
+	if p.emitter == nil {
+		p.seq.text = p.printer()
+	}
+
+	return p.seq.Parse(r)
+}
+
+// parseArray flattens the root array that starts in the scanner. The
+// scanner splits it in chunks, the workers flatten them and the values
+// are emitted from this goroutine.
+func (p *Parallel) parseArray() error {
+	n := p.options.workers
+	if n <= 0 {
+		n = runtime.GOMAXPROCS(0)
+	}
+
+	// the input is already limited and cleaned by Parallel
+	p.pool.start(n, append(slices.Clip(p.opts),
+		WithMaxValues(0),
+		WithMaxInputSize(0),
+		WithLenient(false),
+		WithStrict(false),
+	))
+
+	return p.pool.run(p.scan, work, p.deliver, p.options.unordered)
+}
+
+// work flattens a chunk of array elements.
+func work(w *worker, c *chunk) {
+	c.reset()
+	if err := w.parse(c, c.data, c.offset); err != nil {
+		c.errs = append(c.errs, err)
+	}
+}
+
+// scan splits the root array in chunks and sends them to the workers. A
+// truncated array is sent as is so the worker reports the error.
+func (p *Parallel) scan(jobs chan<- *chunk, done <-chan struct{}) error {
+	s := &p.scanner
+
+	// skip the '['
+	s.pos++
+
+	var c *chunk
+	for seq, index := 0, 0; ; index++ {
+		if c == nil {
+			c = p.pool.get(done)
+			if c == nil {
+				return nil
+			}
+
+			c.seq = seq
+			c.offset = index
+			c.data = append(c.data[:0], '[')
+			seq++
+		} else {
+			c.data = append(c.data, ',')
+		}
+
+		mark := len(c.data)
+
+		var end byte
+		var err error
+		c.data, end, err = s.element(c.data)
+		// an empty element is only valid in an empty array
+		if err == nil && end != 0 && (index > 0 || end == ',') &&
+			len(bytes.TrimSpace(c.data[mark:])) == 0 {
+			err = fmt.Errorf("%w: empty element %d in array", ErrSyntax, index)
+		}
+
+		if err != nil {
+			// send the complete elements before the error
+			if mark > 1 {
+				c.data = append(c.data[:mark-1], ']')
+				jobs <- c
+			} else {
+				p.pool.free <- c
+			}
+			return err
+		}
+
+		switch end {
+		case ',':
+			if len(c.data) < p.chunkSize {
+				continue
+			}
+			c.data = append(c.data, ']')
+			jobs <- c
+			c = nil
+
+		case ']':
+			c.data = append(c.data, ']')
+			jobs <- c
+			return nil
+
+		default:
+			// end of input inside the array
+			jobs <- c
+			return nil
+		}
+	}
+}
+
+// deliver calls the emitter with the values of a flattened chunk.
+func (p *Parallel) deliver(c *chunk) error {
+	start := 0
+	for _, it := range c.items {
+		if err := p.checkValues(); err != nil {
+			return err
+		}
+
+		v := it.value
+		if v.Kind == KindString {
+			v.Str = p.borrow(c.buf[it.keyEnd:it.strEnd])
+		}
+
+		if !p.call(c.buf[start:it.keyEnd], v) {
+			return errExit
+		}
+
+		start = it.strEnd
+	}
+
+	if len(c.errs) > 0 {
+		return c.errs[0]
+	}
+
+	return nil
+}
+
+// scanner finds the boundaries of the elements of the root array without
+// parsing them.
+type scanner struct {
+	r   io.Reader
+	buf []byte
+	// own is the read buffer, buf points to the input with resetBytes
+	own []byte
+	pos int
+	end int
+	err error
+}
+
+func (s *scanner) reset(r io.Reader) {
+	s.r = r
+	s.pos = 0
+	s.end = 0
+	s.err = nil
+
+	if s.own == nil {
+		s.own = make([]byte, scanSize)
+	}
+	s.buf = s.own
+}
+
+// resetBytes scans b instead of reading.
+func (s *scanner) resetBytes(b []byte) {
+	s.r = nil
+	s.buf = b
+	s.pos = 0
+	s.end = len(b)
+	s.err = io.EOF
+}
+
+// fill reads more data when the buffer is consumed. It returns false when
+// there is no more data.
+func (s *scanner) fill() bool {
+	for s.pos == s.end {
+		if s.err != nil {
+			return false
+		}
+
+		s.pos = 0
+		s.end, s.err = s.r.Read(s.buf)
+	}
+
+	return true
+}
+
+// error returns the read error, nil at the end of the input.
+func (s *scanner) error() error {
+	if errors.Is(s.err, io.EOF) {
+		return nil
+	}
+
+	return s.err
+}
+
+// peek skips whitespace and returns the next byte.
+func (s *scanner) peek() (byte, bool) {
+	for s.fill() {
+		for ; s.pos < s.end; s.pos++ {
+			switch c := s.buf[s.pos]; c {
+			case ' ', '\t', '\n', '\r':
+			default:
+				return c, true
+			}
+		}
+	}
+
+	return 0, false
+}
+
+// element appends the next element of the array to dst and returns the
+// byte that ends it, ',' or ']'. At the end of the input it returns 0.
+// Invalid elements are copied and reported by the worker.
+func (s *scanner) element(dst []byte) ([]byte, byte, error) {
+	var depth int
+	var str, escaped bool
+
+	for s.fill() {
+		buf := s.buf[s.pos:s.end]
+		for i, c := range buf {
+			if str {
+				switch {
+				case escaped:
+					escaped = false
+				case c == '\\':
+					escaped = true
+				case c == '"':
+					str = false
+				}
+				continue
+			}
+
+			switch c {
+			case '"':
+				str = true
+			case '{', '[':
+				depth++
+			case '}', ']':
+				if depth > 0 {
+					depth--
+					continue
+				}
+				if c == '}' {
+					continue
+				}
+				fallthrough
+			case ',':
+				if depth > 0 {
+					continue
+				}
+				s.pos += i + 1
+				return append(dst, buf[:i]...), c, nil
+			}
+		}
+
+		dst = append(dst, buf...)
+		s.pos = s.end
+	}
+
+	return dst, 0, s.error()
+}
+
+// rest returns a reader with the data not consumed by the scanner.
+func (s *scanner) rest() io.Reader {
+	r := s.r
+	if s.err != nil {
+		r = errReader{err: s.err}
+	}
+
+	return io.MultiReader(bytes.NewReader(s.buf[s.pos:s.end]), r)
+}
+
+type errReader struct {
+	err error
+}
+
+func (e errReader) Read([]byte) (int, error) {
+	return 0, e.err
+}
@@ -0,0 +1,159 @@
+package jsonflatten
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// arrayDoc returns an array with n objects.
+func arrayDoc(n int) string {
+	var b strings.Builder
+	b.WriteString("[\n")
+	for i := range n {
+		if i > 0 {
+			b.WriteString(",\n")
+		}
+		fmt.Fprintf(&b, `{"id": %d, "name": "n\"%d]", "tags": ["a", {"b": [%d]}]}`,
+			i, i, i)
+	}
+	b.WriteString("\n]")
+	return b.String()
+}
+
+func emitted(t *testing.T, f flattener, doc string) []pair {
+	t.Helper()
+
+	var pairs []pair
+	switch p := f.(type) {
+	case *Parallel:
+		p.Reset(func(k string, v any) bool {
+			pairs = append(pairs, pair{Key: k, Value: v})
+			return true
+		})
+	case *ParserPitr:
+		p.Reset(func(k string, v any) bool {
+			pairs = append(pairs, pair{Key: k, Value: v})
+			return true
+		})
+	}
+
+	err := f.Parse(strings.NewReader(doc))
+	require.NoError(t, err)
+
+	return pairs
+}
+
+func TestParallel(t *testing.T) {
+	doc := arrayDoc(5000)
+	expected := emitted(t, NewParserPitr(nil), doc)
+	require.Len(t, expected, 5000*4)
+
+	p := NewParallel(nil, WithWorkers(4))
+	p.chunkSize = 1000
+
+	// parsers are reused
+	for range 3 {
+		require.Equal(t, expected, emitted(t, p, doc))
+	}
+
+	p = NewParallel(nil, WithWorkers(4), WithUnordered(true))
+	p.chunkSize = 1000
+
+	pairs := emitted(t, p, doc)
+	sortPairs(pairs)
+
+	sorted := slices.Clone(expected)
+	sortPairs(sorted)
+
+	require.Equal(t, sorted, pairs)
+}
+
+func TestParallelStop(t *testing.T) {
+	doc := arrayDoc(1000)
+
+	for _, unordered := range []bool{false, true} {
+		t.Run(fmt.Sprint("unordered=", unordered), func(t *testing.T) {
+			var count int
+			emitter := func(k string, v any) bool {
+				count++
+				return count < 100
+			}
+
+			p := NewParallel(emitter, WithUnordered(unordered))
+			p.chunkSize = 100
+
+			err := p.Parse(strings.NewReader(doc))
+			require.NoError(t, err)
+			require.Equal(t, 100, count)
+
+			// the chunks are not lost after stopping
+			for range 3 {
+				count = 0
+				p.Reset(emitter)
+				err = p.Parse(strings.NewReader(doc))
+				require.NoError(t, err)
+				require.Equal(t, 100, count)
+			}
+		})
+	}
+}
+
+func TestParallelErrors(t *testing.T) {
+	doc := arrayDoc(100)
+	bad := doc[:len(doc)-1] + `, {"a": 1, "a": 2}]`
+
+	p := NewParallel(func(k string, v any) bool { return true },
+		WithDuplicateKeys(DuplicateError))
+	p.chunkSize = 100
+
+	err := p.Parse(strings.NewReader(bad))
+	require.ErrorIs(t, err, ErrDuplicateKey)
+	require.ErrorContains(t, err, "100.a")
+
+	p = NewParallel(nil, WithMaxValues(250))
+	p.chunkSize = 100
+
+	var count int
+	p.Reset(func(k string, v any) bool {
+		count++
+		return true
+	})
+	err = p.Parse(strings.NewReader(doc))
+	require.ErrorIs(t, err, ErrMaxValues)
+	require.Equal(t, 250, count)
+
+	_, err = collect(t, flatteners["parallel"], doc[:len(doc)/2])
+	require.ErrorIs(t, err, ErrTruncated)
+}
+
+func TestParallelDocuments(t *testing.T) {
+	doc := `[1, [2]] {"a": [3]} [4]`
+
+	res, err := collect(t, flatteners["parallel"], doc)
+	require.NoError(t, err)
+	require.Equal(t, []pair{
+		{"0", 1.0},
+		{"0", 4.0},
+		{"1.0", 2.0},
+		{"a.0", 3.0},
+	}, res)
+
+	_, err = collect(t, flatteners["parallel"], doc, WithStrict(true))
+	require.ErrorIs(t, err, ErrTrailingData)
+
+	res, err = collect(t, flatteners["parallel"], `[]`)
+	require.NoError(t, err)
+	require.Empty(t, res)
+}
+
+func TestParallelDocumentsOutput(t *testing.T) {
+	var buf bytes.Buffer
+	p := NewParallel(nil, WithOutput(&buf))
+	require.NoError(t, p.Parse(strings.NewReader(`[1, 2] {"a": 1} [3]`)))
+	require.Equal(t, "0 = 1\n1 = 2\na = 1\n0 = 3\n", buf.String())
+}
@@ -2,7 +2,6 @@ package jsonflatten
 
 import (
 	"encoding/json"
-	"errors"
 	"fmt"
 	"io"
 )
@@ -18,91 +17,61 @@ type Parser struct {
 
 // NewParser creates a new parser using standard tokenizer. If emitter is
 // nil a default printer is used.
-func NewParser(emitter Emitter) *Parser {
+func NewParser(emitter Emitter, opts ...Option) *Parser {
 	return &Parser{
-		commonParser: newCommonParser(emitter),
+		commonParser: newCommonParser(emitter, opts),
 	}
 }
 
 // Parse json and call the provided emitter for each value.
 func (p *Parser) Parse(r io.Reader) error {
-	dec := json.NewDecoder(r)
-
-	for {
-		token, err := dec.Token()
-		if err != nil {
-			if errors.Is(err, io.EOF) {
-				return nil
-			}
-			return err
-		}
-
-		switch v := token.(type) {
-		case json.Delim:
-			switch v {
-			case '{':
-				p.pushState(TypeObject)
-
-			case '}':
-				s := p.popState()
-				if s.jsonType != TypeObject {
-					return fmt.Errorf("invalid char %s", string(v))
-				}
-
-				p.lastState().advance()
-
-			case '[':
-				p.pushState(TypeArray)
+	r, err := p.decoderInput(r)
+	if err != nil {
+		return err
+	}
 
-			case ']':
-				s := p.popState()
-				if s.jsonType != TypeArray {
-					return fmt.Errorf("invalid char %s", string(v))
-				}
+	return p.flatten(&jsonTokenizer{dec: json.NewDecoder(r)})
+}
 
-				p.lastState().advance()
+// jsonTokenizer reads tokens with the standard library decoder.
+type jsonTokenizer struct {
+	dec *json.Decoder
+}
 
-			default:
-				return fmt.Errorf("invalid delimiter %s", string(v))
-			}
+func (t *jsonTokenizer) next() (token, error) {
+	tok, err := t.dec.Token()
+	if err != nil {
+		return token{}, syntaxError(err)
+	}
 
-		case string:
-			s := p.lastState()
-			if s == nil {
-				return fmt.Errorf("single strings not supported")
-			}
+	switch v := tok.(type) {
+	case json.Delim:
+		switch v {
+		case '{':
+			return objectStart, nil
+		case '}':
+			return objectEnd, nil
+		case '[':
+			return arrayStart, nil
+		case ']':
+			return arrayEnd, nil
+		default:
+			return token{}, fmt.Errorf("%w: invalid delimiter %s", ErrSyntax, string(v))
+		}
 
-			switch s.jsonType {
-			case TypeObject:
-				if s.key == "" {
-					s.key = v
-				} else {
-					if !p.emit(s.key, v) {
-						return nil
-					}
-					s.key = ""
-				}
+	case string:
+		return valueToken(stringValue(v)), nil
 
-			case TypeArray:
-				if !p.emit(s.key, v) {
-					return nil
-				}
-				s.advance()
+	case float64:
+		return valueToken(numberValue(v)), nil
 
-			default:
-				return fmt.Errorf("invalid type %v", s.jsonType)
-			}
+	case bool:
+		return valueToken(boolValue(v)), nil
 
-		case float64, bool, nil:
-			if err := p.commonEmitter(v); err != nil {
-				if errors.Is(err, errExit) {
-					return nil
-				}
-				return err
-			}
+	case nil:
+		return valueToken(nullValue), nil
 
-		default:
-			return fmt.Errorf("invalid type: %+v", v)
-		}
+	default:
+		return token{}, fmt.Errorf("%w: invalid type %+v", ErrSyntax, v)
 	}
 }
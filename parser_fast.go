@@ -0,0 +1,536 @@
+package jsonflatten
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+const fastReadSize = 64 * 1024
+
+var (
+	// stringStop marks the bytes that end the fast scan of a string.
+	stringStop [256]bool
+	// numberByte marks the bytes that can be part of a number.
+	numberByte [256]bool
+)
+
+func init() {
+	for c := range ' ' {
+		stringStop[c] = true
+	}
+	stringStop['"'] = true
+	stringStop['\\'] = true
+
+	for _, c := range []byte("0123456789+-.eE") {
+		numberByte[c] = true
+	}
+}
+
+// ParserFast implements a json value flattener with its own tokenizer. It
+// scans the input bytes directly, only unescapes strings that contain
+// backslashes and copies object keys directly to the path buffer.
+type ParserFast struct {
+	commonParser
+
+	tok fastTokenizer
+}
+
+// NewParserFast creates a new parser using its own tokenizer. If emitter is
+// nil a default printer is used.
+func NewParserFast(emitter Emitter, opts ...Option) *ParserFast {
+	p := &ParserFast{
+		commonParser: newCommonParser(emitter, opts),
+	}
+	p.tok.mode = p.options.utf8
+	p.tok.maxKey = p.options.limits.keyLength
+	p.tok.maxString = p.options.limits.stringLength
+
+	return p
+}
+
+// Parse json and call the provided emitter for each value.
+func (p *ParserFast) Parse(r io.Reader) error {
+	p.tok.reset(p.input(r))
+	return p.flatten(&p.tok)
+}
+
+// fastTokenizer reads json tokens directly from the input bytes and checks
+// their order with grammar.
+type fastTokenizer struct {
+	r   io.Reader
+	buf []byte
+	// own is the read buffer, buf points to the input with resetBytes
+	own    []byte
+	pos    int
+	end    int
+	offset int64
+	err    error
+
+	grammar
+	str   []byte
+	valid []byte
+	mode  UTF8Mode
+
+	// maxKey and maxString are the length limits checked while strings
+	// are scanned, before they are completely buffered
+	maxKey    int
+	maxString int
+}
+
+func (t *fastTokenizer) reset(r io.Reader) {
+	if t.own == nil {
+		t.own = make([]byte, fastReadSize)
+	}
+
+	t.r = r
+	t.buf = t.own
+	t.pos = 0
+	t.end = 0
+	t.offset = 0
+	t.err = nil
+	t.grammar.reset()
+}
+
+// resetBytes scans b instead of reading. b is used until the next reset.
+func (t *fastTokenizer) resetBytes(b []byte) {
+	t.reset(nil)
+	t.buf = b
+	t.end = len(b)
+	t.err = io.EOF
+}
+
+func (t *fastTokenizer) next() (token, error) {
+	for {
+		c, ok := t.peek()
+		if !ok {
+			return token{}, t.err
+		}
+
+		switch c {
+		case ',':
+			if !t.comma() {
+				return token{}, t.syntax(c)
+			}
+			t.pos++
+
+		case ':':
+			if !t.colon() {
+				return token{}, t.syntax(c)
+			}
+			t.pos++
+
+		default:
+			return t.token(c)
+		}
+	}
+}
+
+// token reads the token that starts with c.
+func (t *fastTokenizer) token(c byte) (token, error) {
+	switch c {
+	case '{':
+		if !t.open(TypeObject) {
+			return token{}, t.syntax(c)
+		}
+		t.pos++
+		return objectStart, nil
+
+	case '[':
+		if !t.open(TypeArray) {
+			return token{}, t.syntax(c)
+		}
+		t.pos++
+		return arrayStart, nil
+
+	case '}':
+		if !t.close(TypeObject) {
+			return token{}, t.syntax(c)
+		}
+		t.pos++
+		return objectEnd, nil
+
+	case ']':
+		if !t.close(TypeArray) {
+			return token{}, t.syntax(c)
+		}
+		t.pos++
+		return arrayEnd, nil
+
+	case '"':
+		key := t.expect == expectKey || t.expect == expectKeyOrEnd
+		if !t.text() {
+			return token{}, t.syntax(c)
+		}
+
+		b, err := t.string(key)
+		if err != nil {
+			return token{}, err
+		}
+
+		return borrowedToken(unsafeString(b)), nil
+
+	case 't':
+		return t.literal("true", boolValue(true))
+
+	case 'f':
+		return t.literal("false", boolValue(false))
+
+	case 'n':
+		return t.literal("null", nullValue)
+
+	default:
+		if c != '-' && (c < '0' || c > '9') {
+			return token{}, t.syntax(c)
+		}
+		return t.number()
+	}
+}
+
+// peek skips whitespace and returns the next byte without consuming it.
+func (t *fastTokenizer) peek() (byte, bool) {
+	for {
+		for ; t.pos < t.end; t.pos++ {
+			switch c := t.buf[t.pos]; c {
+			case ' ', '\t', '\n', '\r':
+			default:
+				return c, true
+			}
+		}
+
+		if !t.more() {
+			return 0, false
+		}
+	}
+}
+
+// more reads more input keeping the bytes of the current token, the ones
+// from pos on. It returns false at the end of the input.
+func (t *fastTokenizer) more() bool {
+	if t.err != nil {
+		return false
+	}
+
+	n := copy(t.buf, t.buf[t.pos:t.end])
+	if n == len(t.buf) {
+		// the token does not fit in the buffer
+		t.buf = append(t.buf, make([]byte, len(t.buf))...)
+		t.own = t.buf
+	}
+
+	t.offset += int64(t.pos)
+	t.pos = 0
+	t.end = n
+
+	for {
+		m, err := t.r.Read(t.buf[t.end:])
+		t.end += m
+		if err != nil {
+			t.err = err
+			return m > 0
+		}
+		if m > 0 {
+			return true
+		}
+	}
+}
+
+// truncated returns the error for input that ends inside a token.
+func (t *fastTokenizer) truncated() error {
+	if errors.Is(t.err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+
+	return t.err
+}
+
+func (t *fastTokenizer) syntax(c byte) error {
+	return t.syntaxAt(t.pos, c)
+}
+
+func (t *fastTokenizer) syntaxAt(pos int, c byte) error {
+	return fmt.Errorf("%w: invalid character %q at offset %d", ErrSyntax, c,
+		t.offset+int64(pos))
+}
+
+// string reads the string that starts at pos. The returned bytes are only
+// valid until the next read. key tells which length limit applies.
+func (t *fastTokenizer) string(key bool) ([]byte, error) {
+	escaped := false
+	i := 1
+	for {
+		buf := t.buf[t.pos:t.end]
+		for i < len(buf) {
+			for i < len(buf) && !stringStop[buf[i]] {
+				i++
+			}
+			if i == len(buf) {
+				break
+			}
+
+			switch c := buf[i]; c {
+			case '"':
+				raw := buf[1:i]
+				t.pos += i + 1
+				if escaped {
+					var err error
+					if raw, err = t.unescape(raw); err != nil {
+						return nil, err
+					}
+				}
+				return validUTF8(&t.valid, raw, t.mode)
+
+			case '\\':
+				// the escaped byte is checked by unescape
+				escaped = true
+				i += 2
+
+			default:
+				return nil, t.syntaxAt(t.pos+i, c)
+			}
+		}
+
+		if err := t.checkLength(key, i-1, escaped); err != nil {
+			return nil, err
+		}
+
+		if !t.more() {
+			return nil, t.truncated()
+		}
+	}
+}
+
+// checkLength fails when the n bytes of a string read so far already exceed
+// the length limit, so a long string is not buffered before the flattener
+// rejects it. Escape sequences are at most 6 bytes for each byte they
+// decode to, the exact length of escaped strings is checked by the
+// flattener.
+func (t *fastTokenizer) checkLength(key bool, n int, escaped bool) error {
+	limit, err := t.maxString, ErrMaxStringLength
+	if key {
+		limit, err = t.maxKey, ErrMaxKeyLength
+	}
+
+	if escaped {
+		n /= 6
+	}
+
+	if limit > 0 && n > limit {
+		return fmt.Errorf("%w: %d", err, limit)
+	}
+
+	return nil
+}
+
+// unescape decodes the escape sequences of a string.
+func (t *fastTokenizer) unescape(raw []byte) ([]byte, error) {
+	b, err := unescapeString(t.str[:0], raw, t.mode)
+	if err != nil {
+		return nil, err
+	}
+
+	t.str = b
+	return b, nil
+}
+
+// unescapeString appends raw to b decoding its escape sequences. Invalid
+// escapes fail with ErrSyntax and lone surrogates are handled with mode.
+func unescapeString(b, raw []byte, mode UTF8Mode) ([]byte, error) {
+	for {
+		i := bytes.IndexByte(raw, '\\')
+		if i < 0 {
+			b = append(b, raw...)
+			break
+		}
+
+		b = append(b, raw[:i]...)
+		raw = raw[i:]
+
+		if len(raw) < 2 {
+			return nil, fmt.Errorf("%w: invalid escape %q", ErrSyntax, raw)
+		}
+
+		n := 2
+		switch c := raw[1]; c {
+		case '"', '\\', '/':
+			b = append(b, c)
+		case 'b':
+			b = append(b, '\b')
+		case 'f':
+			b = append(b, '\f')
+		case 'n':
+			b = append(b, '\n')
+		case 'r':
+			b = append(b, '\r')
+		case 't':
+			b = append(b, '\t')
+		case 'u':
+			var err error
+			b, n, err = unicodeEscape(b, raw, mode)
+			if err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("%w: invalid escape %q", ErrSyntax, raw[:2])
+		}
+
+		raw = raw[n:]
+	}
+
+	return b, nil
+}
+
+// unicodeEscape appends the character of the \u escape at the start of raw
+// and returns the number of bytes used. Lone surrogates are handled with
+// mode.
+func unicodeEscape(b, raw []byte, mode UTF8Mode) ([]byte, int, error) {
+	if len(raw) < 6 {
+		return nil, 0, fmt.Errorf("%w: invalid escape %q", ErrSyntax, raw)
+	}
+
+	r, ok := parseHex(raw[2:6])
+	if !ok {
+		return nil, 0, fmt.Errorf("%w: invalid escape %q", ErrSyntax, raw[:6])
+	}
+
+	if !utf16.IsSurrogate(r) {
+		return utf8.AppendRune(b, r), 6, nil
+	}
+
+	if r < 0xdc00 && len(raw) >= 12 && raw[6] == '\\' && raw[7] == 'u' {
+		low, ok := parseHex(raw[8:12])
+		if ok && low >= 0xdc00 && low <= 0xdfff {
+			return utf8.AppendRune(b, utf16.DecodeRune(r, low)), 12, nil
+		}
+	}
+
+	switch mode {
+	case UTF8Reject:
+		return nil, 0, fmt.Errorf("%w: lone surrogate %q", ErrInvalidUTF8,
+			raw[:6])
+	case UTF8Replace:
+		return utf8.AppendRune(b, utf8.RuneError), 6, nil
+	default:
+		return append(b,
+			0xe0|byte(r>>12),
+			0x80|byte(r>>6)&0x3f,
+			0x80|byte(r)&0x3f,
+		), 6, nil
+	}
+}
+
+// number reads the number that starts at pos.
+func (t *fastTokenizer) number() (token, error) {
+	if !t.value() {
+		return token{}, t.syntax(t.buf[t.pos])
+	}
+
+	i := 0
+	for {
+		buf := t.buf[t.pos:t.end]
+		for i < len(buf) && numberByte[buf[i]] {
+			i++
+		}
+		if i < len(buf) {
+			break
+		}
+
+		if !t.more() {
+			if !errors.Is(t.err, io.EOF) {
+				return token{}, t.err
+			}
+			break
+		}
+	}
+
+	b := t.buf[t.pos : t.pos+i]
+	if !validNumber(b) {
+		return token{}, fmt.Errorf("%w: invalid number %q at offset %d",
+			ErrSyntax, b, t.offset+int64(t.pos))
+	}
+
+	v, err := strconv.ParseFloat(unsafeString(b), 64)
+	if err != nil {
+		return token{}, fmt.Errorf("%w: %w", ErrSyntax, err)
+	}
+	t.pos += i
+
+	return valueToken(numberValue(v)), nil
+}
+
+// literal reads true, false or null.
+func (t *fastTokenizer) literal(lit string, v Value) (token, error) {
+	if !t.value() {
+		return token{}, t.syntax(t.buf[t.pos])
+	}
+
+	for t.end-t.pos < len(lit) {
+		if !t.more() {
+			if bytes.HasPrefix([]byte(lit), t.buf[t.pos:t.end]) {
+				return token{}, t.truncated()
+			}
+			break
+		}
+	}
+
+	if t.end-t.pos < len(lit) || string(t.buf[t.pos:t.pos+len(lit)]) != lit {
+		return token{}, fmt.Errorf("%w: invalid literal at offset %d",
+			ErrSyntax, t.offset+int64(t.pos))
+	}
+	t.pos += len(lit)
+
+	return valueToken(v), nil
+}
+
+// validNumber checks the json number grammar.
+func validNumber(b []byte) bool {
+	i := 0
+	if i < len(b) && b[i] == '-' {
+		i++
+	}
+
+	switch {
+	case i < len(b) && b[i] == '0':
+		i++
+	case i < len(b) && b[i] >= '1' && b[i] <= '9':
+		i = digits(b, i)
+	default:
+		return false
+	}
+
+	if i < len(b) && b[i] == '.' {
+		j := digits(b, i+1)
+		if j == i+1 {
+			return false
+		}
+		i = j
+	}
+
+	if i < len(b) && (b[i] == 'e' || b[i] == 'E') {
+		i++
+		if i < len(b) && (b[i] == '+' || b[i] == '-') {
+			i++
+		}
+		j := digits(b, i)
+		if j == i {
+			return false
+		}
+		i = j
+	}
+
+	return i == len(b)
+}
+
+// digits returns the position of the first byte from i that is not a
+// digit.
+func digits(b []byte, i int) int {
+	for i < len(b) && b[i] >= '0' && b[i] <= '9' {
+		i++
+	}
+
+	return i
+}
@@ -0,0 +1,124 @@
+package jsonflatten
+
+import (
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParserFastSyntax(t *testing.T) {
+	invalid := []string{
+		`{"a" 1}`,
+		`{"a": 1 "b": 2}`,
+		`{"a": 1,}`,
+		`{,}`,
+		`{1: 2}`,
+		`[1 2]`,
+		`[1,]`,
+		`[,1]`,
+		`[1}`,
+		`{"a": 1]`,
+		`{"a": [}`,
+		`["a" : 1]`,
+		`{"a": tru}`,
+		`{"a": nul}`,
+		`{"a": 01}`,
+		`{"a": 1.}`,
+		`{"a": .5}`,
+		`{"a": -}`,
+		`{"a": 1e}`,
+		`{"a": +1}`,
+		`{"a": 0x10}`,
+		`{"a": "\x"}`,
+		`{"a": "\u12"}`,
+		"{\"a\": \"\t\"}",
+		`}`,
+		`{"a": 1}}`,
+	}
+
+	for _, doc := range invalid {
+		t.Run(doc, func(t *testing.T) {
+			p := NewParserFast(func(k string, v any) bool { return true })
+			err := p.Parse(strings.NewReader(doc))
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestParserFastTokens(t *testing.T) {
+	long := strings.Repeat("long ", fastReadSize/2)
+	doc := `{
+		"escaped \"key\"": "a\\b\/c\n\té😀",
+		"numbers": [0, -0.5, 1e3, 2E-2, 12345678901234567890],
+		"literals": [true, false, null],
+		"empty": "",
+		"` + long + `": "` + long + `"
+	}`
+
+	expected := []pair{
+		{"empty", ""},
+		{`escaped "key"`, "a\\b/c\n\té😀"},
+		{"literals.0", true},
+		{"literals.1", false},
+		{"literals.2", nil},
+		{long, long},
+		{"numbers.0", 0.0},
+		{"numbers.1", -0.5},
+		{"numbers.2", 1000.0},
+		{"numbers.3", 0.02},
+		{"numbers.4", 12345678901234567890.0},
+	}
+
+	pairs, err := collect(t, flatteners["fast"], doc)
+	require.NoError(t, err)
+	require.Equal(t, expected, pairs)
+
+	// tokens split between reads
+	var split []pair
+	p := NewParserFast(func(k string, v any) bool {
+		split = append(split, pair{Key: k, Value: v})
+		return true
+	})
+	err = p.Parse(iotest.OneByteReader(strings.NewReader(doc)))
+	require.NoError(t, err)
+	sortPairs(split)
+	require.Equal(t, expected, split)
+
+	// scanned directly from the bytes
+	split = nil
+	p.Reset(p.emitter)
+	err = p.ParseBytes([]byte(doc))
+	require.NoError(t, err)
+	sortPairs(split)
+	require.Equal(t, expected, split)
+}
+
+func TestParserFastParseBytesSurrogates(t *testing.T) {
+	doc := []byte(`{"a": "x\ud800y"}`)
+
+	tests := []struct {
+		mode     UTF8Mode
+		expected string
+	}{
+		{mode: UTF8Replace, expected: "x�y"},
+		{mode: UTF8PassThrough, expected: "x\xed\xa0\x80y"},
+	}
+
+	for _, test := range tests {
+		var value any
+		p := NewParserFast(func(k string, v any) bool {
+			value = v
+			return true
+		}, WithInvalidUTF8(test.mode))
+
+		err := p.ParseBytes(doc)
+		require.NoError(t, err)
+		require.Equal(t, test.expected, value)
+	}
+
+	p := NewParserFast(nil, WithInvalidUTF8(UTF8Reject))
+	err := p.ParseBytes(doc)
+	require.ErrorIs(t, err, ErrInvalidUTF8)
+}
@@ -1,11 +1,11 @@
 package jsonflatten
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"io"
 	"strconv"
-	"strings"
 
 	"pitr.ca/jsontokenizer"
 )
@@ -13,6 +13,8 @@ import (
 // ParserPitr implements a json value flattener using Pitr tokenizer.
 type ParserPitr struct {
 	commonParser
+
+	tok pitrTokenizer
 }
 
 const (
@@ -21,135 +23,188 @@ const (
 
 // NewParserPitr creates a new parser using Pitr tokenizer. If emitter is nil
 // a default printer is used.
-func NewParserPitr(emitter Emitter) *ParserPitr {
-	return &ParserPitr{
-		commonParser: newCommonParser(emitter),
+func NewParserPitr(emitter Emitter, opts ...Option) *ParserPitr {
+	p := &ParserPitr{
+		commonParser: newCommonParser(emitter, opts),
 	}
+	p.tok.mode = p.options.utf8
+
+	return p
 }
 
 // Parse json and call the provided emitter for each value.
 func (p *ParserPitr) Parse(r io.Reader) error {
-	buf := new(strings.Builder)
+	p.tok.in = readErrors{r: p.input(r)}
+	p.tok.dec = jsontokenizer.NewWithSize(&p.tok.in, readSize)
+	p.tok.grammar.reset()
+	return p.flatten(&p.tok)
+}
 
-	dec := jsontokenizer.NewWithSize(r, readSize)
+// pitrTokenizer reads tokens with Pitr tokenizer. It does not check the
+// order of the tokens, the number syntax or the characters and escapes of
+// the strings, this is done by the adapter.
+type pitrTokenizer struct {
+	grammar
+
+	dec   jsontokenizer.Tokenizer
+	in    readErrors
+	buf   bytes.Buffer
+	str   []byte
+	valid []byte
+	mode  UTF8Mode
+}
 
+func (t *pitrTokenizer) next() (token, error) {
 	for {
-		token, err := dec.Token()
+		tok, err := t.dec.Token()
 		if err != nil {
-			if errors.Is(err, io.EOF) {
-				return nil
-			}
-			return err
+			return token{}, t.error(err)
 		}
 
-		switch token {
+		switch tok {
 		case jsontokenizer.TokObjectOpen:
-			p.pushState(TypeObject)
+			if !t.open(TypeObject) {
+				return token{}, unexpected("'{'")
+			}
+			return objectStart, nil
 
 		case jsontokenizer.TokObjectClose:
-			s := p.popState()
-			if s.jsonType != TypeObject {
-				return fmt.Errorf("invalid char %d", token)
+			if !t.close(TypeObject) {
+				return token{}, unexpected("'}'")
 			}
-
-			p.lastState().advance()
+			return objectEnd, nil
 
 		case jsontokenizer.TokArrayOpen:
-			p.pushState(TypeArray)
+			if !t.open(TypeArray) {
+				return token{}, unexpected("'['")
+			}
+			return arrayStart, nil
 
 		case jsontokenizer.TokArrayClose:
-			s := p.popState()
-			if s.jsonType != TypeArray {
-				return fmt.Errorf("invalid char %d", token)
+			if !t.close(TypeArray) {
+				return token{}, unexpected("']'")
 			}
+			return arrayEnd, nil
 
-			p.lastState().advance()
+		case jsontokenizer.TokComma:
+			if !t.comma() {
+				return token{}, unexpected("','")
+			}
 
-		case jsontokenizer.TokString:
-			s := p.lastState()
-			if s == nil {
-				return fmt.Errorf("single strings not supported")
+		case jsontokenizer.TokObjectColon:
+			if !t.colon() {
+				return token{}, unexpected("':'")
 			}
 
-			buf.Reset()
-			_, err := dec.ReadString(buf)
-			if err != nil {
-				return err
+		case jsontokenizer.TokString:
+			if !t.text() {
+				return token{}, unexpected("string")
 			}
 
-			v := buf.String()
-
-			switch s.jsonType {
-			case TypeObject:
-				if s.key == "" {
-					s.key = v
-				} else {
-					if !p.emit(s.key, v) {
-						return nil
-					}
-					s.key = ""
-				}
-
-			case TypeArray:
-				if !p.emit(s.key, v) {
-					return nil
-				}
-				s.advance()
-
-			default:
-				return fmt.Errorf("invalid type %v", s.jsonType)
+			t.buf.Reset()
+			if _, err := t.dec.ReadString(&t.buf); err != nil {
+				return token{}, t.error(err)
 			}
 
+			return t.string(t.buf.Bytes())
+
 		case jsontokenizer.TokNumber:
-			s := p.lastState()
-			if s == nil {
-				return fmt.Errorf("single value not supported")
+			if !t.value() {
+				return token{}, unexpected("number")
 			}
 
-			buf.Reset()
-			_, err := dec.ReadNumber(buf)
-			if err != nil {
-				return err
+			t.buf.Reset()
+			if _, err := t.dec.ReadNumber(&t.buf); err != nil {
+				return token{}, t.error(err)
 			}
 
-			v, err := strconv.ParseFloat(buf.String(), 64)
-			if err != nil {
-				return err
+			b := t.buf.Bytes()
+			if !validNumber(b) {
+				return token{}, fmt.Errorf("%w: invalid number %q", ErrSyntax, b)
 			}
 
-			if !p.emit(s.key, v) {
-				return nil
+			v, err := strconv.ParseFloat(unsafeString(b), 64)
+			if err != nil {
+				return token{}, fmt.Errorf("%w: %w", ErrSyntax, err)
 			}
-			s.advance()
+
+			return valueToken(numberValue(v)), nil
 
 		case jsontokenizer.TokTrue:
-			if err := p.commonEmitter(true); err != nil {
-				if errors.Is(err, errExit) {
-					return nil
-				}
-				return err
-			}
+			return t.literal(boolValue(true))
 
 		case jsontokenizer.TokFalse:
-			if err := p.commonEmitter(false); err != nil {
-				if errors.Is(err, errExit) {
-					return nil
-				}
-				return err
-			}
+			return t.literal(boolValue(false))
 
 		case jsontokenizer.TokNull:
-			if err := p.commonEmitter(nil); err != nil {
-				if errors.Is(err, errExit) {
-					return nil
-				}
-				return err
-			}
-
-		case jsontokenizer.TokComma, jsontokenizer.TokObjectColon:
+			return t.literal(nullValue)
 
 		default:
-			return fmt.Errorf("invalid type: %d", token)
+			return token{}, fmt.Errorf("%w: invalid type %d", ErrSyntax, tok)
+		}
+	}
+}
+
+// error wraps in ErrSyntax the errors of the tokenizer for invalid input.
+// The end of the input and the errors of the reader are returned as they
+// are.
+func (t *pitrTokenizer) error(err error) error {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		(t.in.err != nil && errors.Is(err, t.in.err)) {
+		return err
+	}
+
+	return fmt.Errorf("%w: %w", ErrSyntax, err)
+}
+
+func (t *pitrTokenizer) literal(v Value) (token, error) {
+	if !t.value() {
+		return token{}, unexpected("literal")
+	}
+
+	return valueToken(v), nil
+}
+
+// string checks the bytes of a string as they are in the input, decodes its
+// escape sequences and applies the UTF-8 mode like ParserFast.
+func (t *pitrTokenizer) string(raw []byte) (token, error) {
+	for _, c := range raw {
+		if c < ' ' {
+			return token{}, fmt.Errorf("%w: invalid character %q in string",
+				ErrSyntax, c)
 		}
 	}
+
+	b := raw
+	if bytes.IndexByte(raw, '\\') >= 0 {
+		s, err := unescapeString(t.str[:0], raw, t.mode)
+		if err != nil {
+			return token{}, err
+		}
+		t.str = s
+		b = s
+	}
+
+	b, err := validUTF8(&t.valid, b, t.mode)
+	if err != nil {
+		return token{}, err
+	}
+
+	return borrowedToken(unsafeString(b)), nil
+}
+
+// readErrors keeps the last error returned by the reader, other than io.EOF,
+// to tell it apart from the errors of the tokenizer.
+type readErrors struct {
+	r   io.Reader
+	err error
+}
+
+func (r *readErrors) Read(b []byte) (int, error) {
+	n, err := r.r.Read(b)
+	if err != nil && err != io.EOF {
+		r.err = err
+	}
+
+	return n, err
 }
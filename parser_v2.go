@@ -1,9 +1,10 @@
 package jsonflatten
 
 import (
-	"errors"
+	"bytes"
 	"fmt"
 	"io"
+	"strconv"
 
 	"github.com/go-json-experiment/json/jsontext"
 )
@@ -11,114 +12,121 @@ import (
 // ParserV2 implements a json value flattener using standard library tokenizer.
 type ParserV2 struct {
 	commonParser
+
+	tok jsontextTokenizer
 }
 
 // NewParserV2 creates a new parser using standard tokenizer. If emitter is
 // nil a default printer is used.
-func NewParserV2(emitter Emitter) *ParserV2 {
+func NewParserV2(emitter Emitter, opts ...Option) *ParserV2 {
 	return &ParserV2{
-		commonParser: newCommonParser(emitter),
+		commonParser: newCommonParser(emitter, opts),
 	}
 }
 
 // Parse json and call the provided emitter for each value.
 func (p *ParserV2) Parse(r io.Reader) error {
-	// dec := json.NewDecoder(r)
-	dec := jsontext.NewDecoder(r)
+	return p.parse(p.input(r))
+}
+
+// parse flattens the document read from r. When r is a bytes.Buffer the
+// decoder reads its bytes directly.
+func (p *ParserV2) parse(r io.Reader) error {
+	// duplicated names and invalid UTF-8 are handled by the flattener
+	opts := []jsontext.Options{
+		jsontext.AllowDuplicateNames(true),
+		jsontext.AllowInvalidUTF8(true),
+	}
+
+	// the decoder is kept to reuse its buffers
+	if p.tok.dec == nil {
+		p.tok.dec = jsontext.NewDecoder(r, opts...)
+	} else {
+		p.tok.dec.Reset(r, opts...)
+	}
+
+	p.tok.mode = p.options.utf8
+	return p.flatten(&p.tok)
+}
+
+// jsontextTokenizer reads tokens with the jsontext decoder.
+type jsontextTokenizer struct {
+	dec   *jsontext.Decoder
+	str   []byte
+	valid []byte
+	mode  UTF8Mode
+}
+
+func (t *jsontextTokenizer) next() (token, error) {
+	// strings and numbers are read as raw values to use them without
+	// allocating
+	switch t.dec.PeekKind() {
+	case '"':
+		raw, err := t.dec.ReadValue()
+		if err != nil {
+			return token{}, syntaxError(err)
+		}
+
+		return t.string(raw)
 
-	for {
-		token, err := dec.ReadToken()
+	case '0':
+		raw, err := t.dec.ReadValue()
 		if err != nil {
-			if errors.Is(err, io.EOF) {
-				return nil
-			}
-			return err
+			return token{}, syntaxError(err)
 		}
 
-		switch token.Kind() {
-		case '{':
-			p.pushState(TypeObject)
-
-		case '}':
-			s := p.popState()
-			if s.jsonType != TypeObject {
-				return fmt.Errorf("invalid char %+v", token)
-			}
-
-			p.lastState().advance()
-
-		case '[':
-			p.pushState(TypeArray)
-
-		case ']':
-			s := p.popState()
-			if s.jsonType != TypeArray {
-				return fmt.Errorf("invalid char %+v", token)
-			}
-
-			p.lastState().advance()
-
-		case '"':
-			s := p.lastState()
-			if s == nil {
-				return fmt.Errorf("single strings not supported")
-			}
-
-			switch s.jsonType {
-			case TypeObject:
-				if s.key == "" {
-					s.key = token.String()
-				} else {
-					if !p.emit(s.key, token.String()) {
-						return nil
-					}
-					s.key = ""
-				}
-
-			case TypeArray:
-				if !p.emit(s.key, token.String()) {
-					return nil
-				}
-				s.advance()
-
-			default:
-				return fmt.Errorf("invalid type %v", s.jsonType)
-			}
-
-		case '0':
-			if err := p.commonEmitter(token.Float()); err != nil {
-				if errors.Is(err, errExit) {
-					return nil
-				}
-				return err
-			}
-
-		case 't':
-			if err := p.commonEmitter(true); err != nil {
-				if errors.Is(err, errExit) {
-					return nil
-				}
-				return err
-			}
-
-		case 'f':
-			if err := p.commonEmitter(false); err != nil {
-				if errors.Is(err, errExit) {
-					return nil
-				}
-				return err
-			}
-
-		case 'n':
-			if err := p.commonEmitter(nil); err != nil {
-				if errors.Is(err, errExit) {
-					return nil
-				}
-				return err
-			}
-
-		default:
-			return fmt.Errorf("invalid type: %+v", token)
+		// out of range numbers fail like with the other tokenizers
+		v, err := strconv.ParseFloat(unsafeString(raw), 64)
+		if err != nil {
+			return token{}, syntaxError(err)
+		}
+
+		return valueToken(numberValue(v)), nil
+	}
+
+	tok, err := t.dec.ReadToken()
+	if err != nil {
+		return token{}, syntaxError(err)
+	}
+
+	switch tok.Kind() {
+	case '{':
+		return objectStart, nil
+	case '}':
+		return objectEnd, nil
+	case '[':
+		return arrayStart, nil
+	case ']':
+		return arrayEnd, nil
+	case 't':
+		return valueToken(boolValue(true)), nil
+	case 'f':
+		return valueToken(boolValue(false)), nil
+	case 'n':
+		return valueToken(nullValue), nil
+	default:
+		return token{}, fmt.Errorf("%w: invalid type %+v", ErrSyntax, tok)
+	}
+}
+
+// string returns the token of a raw string, only decoding it when it has
+// escape sequences. The decoder allows invalid UTF-8 and lone surrogates,
+// they are handled with the UTF-8 mode like ParserFast does.
+func (t *jsontextTokenizer) string(raw jsontext.Value) (token, error) {
+	b := raw[1 : len(raw)-1]
+	if bytes.IndexByte(b, '\\') >= 0 {
+		s, err := unescapeString(t.str[:0], b, t.mode)
+		if err != nil {
+			return token{}, syntaxError(err)
 		}
+		t.str = s
+		b = s
 	}
+
+	b, err := validUTF8(&t.valid, b, t.mode)
+	if err != nil {
+		return token{}, err
+	}
+
+	return borrowedToken(unsafeString(b)), nil
 }
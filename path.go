@@ -0,0 +1,106 @@
+package jsonflatten
+
+import "strconv"
+
+const defaultSeparator = "."
+
+// WithSeparator sets the string between the segments of the flattened keys.
+// It is "." by default.
+func WithSeparator(sep string) Option {
+	return func(o *options) {
+		o.separator = sep
+	}
+}
+
+// keySeparator returns the separator of the keys. The options are empty in
+// parsers created with new.
+func (o *options) keySeparator() string {
+	switch {
+	case o.gron != nil:
+		// gron keys start with their own separator
+		return ""
+	case o.separator == "":
+		return defaultSeparator
+	default:
+		return o.separator
+	}
+}
+
+// pathBuffer holds the flattened path of the open containers. Each state
+// keeps the length of its prefix so the buffer is extended when a container
+// starts and the prefix of the parent is reused when it ends, without
+// joining the whole path for each value.
+type pathBuffer []byte
+
+// appendKey appends the current key of the state to the prefix of the
+// state. Object keys are already stored after the prefix.
+func (b pathBuffer) appendKey(s *State) pathBuffer {
+	switch {
+	case s.jsonType == TypeObject:
+		return b[:s.prefix+s.keyLen]
+	case s.wildcard:
+		return append(b[:s.prefix], wildcardKey...)
+	case s.gron:
+		return appendGronIndex(b[:s.prefix], s.arrayCounter)
+	default:
+		return strconv.AppendInt(b[:s.prefix], int64(s.arrayCounter), 10)
+	}
+}
+
+// setKey stores the object key k after the prefix of s, in gron syntax in
+// the containers flattened for Gron.
+func (b *pathBuffer) setKey(s *State, k string) {
+	*b = b.withKey(s, k)
+	s.keyLen = len(*b) - s.prefix
+}
+
+// name returns the current object key of s.
+func (b pathBuffer) name(s *State) string {
+	if s.jsonType != TypeObject {
+		return ""
+	}
+
+	return string(b[s.prefix : s.prefix+s.keyLen])
+}
+
+// extend adds the current key of s and the separator to the path and
+// returns the prefix length of the container that starts there.
+func (b *pathBuffer) extend(s *State, sep string) int {
+	// the root container does not have a key
+	if s.jsonType == TypeUnknown {
+		return 0
+	}
+
+	*b = b.appendKey(s)
+	*b = append(*b, sep...)
+
+	return len(*b)
+}
+
+// key returns the path of the current key of s. It is only valid until the
+// buffer is modified again.
+func (b *pathBuffer) key(s *State) []byte {
+	*b = b.appendKey(s)
+	return *b
+}
+
+// withKey returns the path of key k inside s. It is only valid until the
+// buffer is modified again.
+func (b *pathBuffer) withKey(s *State, k string) []byte {
+	if s.gron {
+		*b = appendGronKey((*b)[:s.prefix], k)
+	} else {
+		*b = append((*b)[:s.prefix], k...)
+	}
+
+	return *b
+}
+
+// of returns the path of the container s, its prefix without the separator.
+func (b pathBuffer) of(s *State, sep string) string {
+	if s.prefix == 0 {
+		return ""
+	}
+
+	return string(b[:s.prefix-len(sep)])
+}
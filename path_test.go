@@ -0,0 +1,59 @@
+package jsonflatten
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPathDeep(t *testing.T) {
+	const depth = 100
+	doc := strings.Repeat(`{"k":[`, depth) + `1` + strings.Repeat(`]}`, depth)
+	key := strings.TrimSuffix(strings.Repeat("k.0.", depth), ".")
+
+	for name, f := range flatteners {
+		t.Run(name, func(t *testing.T) {
+			pairs, err := collect(t, f, doc)
+			require.NoError(t, err)
+			require.Equal(t, []pair{{key, float64(1)}}, pairs)
+		})
+	}
+}
+
+func TestPathSiblings(t *testing.T) {
+	doc := `{"long_key_name": {"a": 1}, "b": {"c": [10, 11]}, "d": 2}`
+	expected := []pair{
+		{"b.c.0", float64(10)},
+		{"b.c.1", float64(11)},
+		{"d", float64(2)},
+		{"long_key_name.a", float64(1)},
+	}
+
+	pairs, err := collect(t, flatteners["pitr"], doc)
+	require.NoError(t, err)
+	require.Equal(t, expected, pairs)
+}
+
+func TestPathSeparator(t *testing.T) {
+	doc := `{"a": {"b": [1, {"c": 2}]}, "d": {"e": [`
+	expected := []pair{
+		{"a/b/0", float64(1)},
+		{"a/b/1/c", float64(2)},
+	}
+
+	for name, f := range flatteners {
+		t.Run(name, func(t *testing.T) {
+			pairs, err := collect(t, f, doc, WithSeparator("/"))
+			require.ErrorIs(t, err, ErrTruncated)
+			if name != "memory" && name != "memoryv2" {
+				require.ErrorContains(t, err, `array not closed at "d/e"`)
+				require.Equal(t, expected, pairs)
+			}
+
+			pairs, err = collect(t, f, `{"a": {"b": 1}}`, WithSeparator(" -> "))
+			require.NoError(t, err)
+			require.Equal(t, []pair{{"a -> b", float64(1)}}, pairs)
+		})
+	}
+}
@@ -0,0 +1,219 @@
+package jsonflatten
+
+import (
+	"bytes"
+	"slices"
+	"sync"
+)
+
+// pool flattens chunks of the input in several goroutines. The chunks are
+// reused and their number limits the work in flight, so reading the input
+// blocks when the emitter is slower than the workers.
+type pool struct {
+	workers []*worker
+	free    chan *chunk
+	pending map[int]*chunk
+}
+
+// start creates n workers with the given options the first time it is
+// called.
+func (p *pool) start(n int, opts []Option) {
+	if p.workers != nil {
+		return
+	}
+
+	p.workers = make([]*worker, n)
+	for i := range p.workers {
+		p.workers[i] = newWorker(opts)
+	}
+
+	p.free = make(chan *chunk, 2*n+1)
+	for range cap(p.free) {
+		p.free <- new(chunk)
+	}
+
+	p.pending = make(map[int]*chunk)
+}
+
+// get returns a free chunk or nil if done is closed. done is checked first
+// as select picks a random case when both are ready, and after an error
+// the chunks are still returned to free.
+func (p *pool) get(done <-chan struct{}) *chunk {
+	select {
+	case <-done:
+		return nil
+	default:
+	}
+
+	select {
+	case c := <-p.free:
+		return c
+	case <-done:
+		return nil
+	}
+}
+
+// run sends the chunks produced by scan to the workers, that flatten them
+// with work, and calls deliver with the results from this goroutine. The
+// chunks are delivered in the order they were scanned unless unordered is
+// set. The first error returned by deliver stops the scan and the
+// remaining chunks are discarded.
+func (p *pool) run(
+	scan func(jobs chan<- *chunk, done <-chan struct{}) error,
+	work func(w *worker, c *chunk),
+	deliver func(c *chunk) error,
+	unordered bool,
+) error {
+	jobs := make(chan *chunk)
+	results := make(chan *chunk, cap(p.free))
+	done := make(chan struct{})
+
+	var wg sync.WaitGroup
+	for _, w := range p.workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for c := range jobs {
+				work(w, c)
+				results <- c
+			}
+		}()
+	}
+
+	var scanErr error
+	go func() {
+		defer close(jobs)
+		scanErr = scan(jobs, done)
+	}()
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	var err error
+	next := 0
+	for c := range results {
+		// after an error the remaining chunks are discarded
+		if err != nil {
+			p.free <- c
+			continue
+		}
+
+		if unordered {
+			err = deliver(c)
+		} else {
+			p.pending[c.seq] = c
+			for err == nil {
+				c = p.pending[next]
+				if c == nil {
+					break
+				}
+
+				delete(p.pending, next)
+				next++
+				err = deliver(c)
+				if err != nil {
+					break
+				}
+				p.free <- c
+			}
+		}
+
+		if err != nil {
+			close(done)
+		}
+		if err != nil || unordered {
+			p.free <- c
+		}
+	}
+
+	// chunks left waiting after an error
+	for seq, c := range p.pending {
+		delete(p.pending, seq)
+		p.free <- c
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return scanErr
+}
+
+// chunk is a part of the input flattened by a worker. offset is the index
+// of its first array element or the number of its first line.
+type chunk struct {
+	seq    int
+	offset int
+	data   []byte
+
+	// buf holds the key and string value of each item one after the other
+	buf   []byte
+	items []item
+	errs  []error
+}
+
+type item struct {
+	record int
+	keyEnd int
+	strEnd int
+	value  Value
+}
+
+// worker flattens chunks storing the values in them or sending them to
+// direct.
+type worker struct {
+	parser *ParserPitr
+	reader bytes.Reader
+	chunk  *chunk
+	record int
+	direct RecordEmitter
+}
+
+func newWorker(opts []Option) *worker {
+	w := new(worker)
+	opts = append(slices.Clip(opts), WithRawEmitter(w.emit))
+	w.parser = NewParserPitr(nil, opts...)
+
+	return w
+}
+
+// parse flattens data with offset as the index of the first element of
+// the root array.
+func (w *worker) parse(c *chunk, data []byte, offset int) error {
+	w.chunk = c
+	w.reader.Reset(data)
+	w.parser.Reset(nil)
+	w.parser.offset = offset
+
+	return w.parser.Parse(&w.reader)
+}
+
+func (w *worker) emit(k []byte, v Value) bool {
+	if w.direct != nil {
+		return w.direct(w.record, string(k), v.clone().Any())
+	}
+
+	c := w.chunk
+	c.buf = append(c.buf, k...)
+	keyEnd := len(c.buf)
+	c.buf = append(c.buf, v.Str...)
+
+	v.Str = ""
+	c.items = append(c.items, item{
+		record: w.record,
+		keyEnd: keyEnd,
+		strEnd: len(c.buf),
+		value:  v,
+	})
+
+	return true
+}
+
+// reset empties the results of the chunk.
+func (c *chunk) reset() {
+	c.buf = c.buf[:0]
+	c.items = c.items[:0]
+	c.errs = c.errs[:0]
+}
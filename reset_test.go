@@ -0,0 +1,118 @@
+package jsonflatten
+
+import (
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type resetter interface {
+	flattener
+	Reset(Emitter)
+}
+
+func TestReset(t *testing.T) {
+	for name, f := range flatteners {
+		t.Run(name, func(t *testing.T) {
+			p := f(nil, WithLenient(true)).(resetter)
+
+			// stop in the middle of the document
+			p.Reset(func(k string, v any) bool {
+				return false
+			})
+			err := p.Parse(strings.NewReader(testJson))
+			require.NoError(t, err)
+
+			for range 3 {
+				m := make(map[string]any)
+				p.Reset(func(k string, v any) bool {
+					m[k] = v
+					return true
+				})
+
+				err := p.Parse(strings.NewReader(testJson))
+				require.NoError(t, err)
+				require.Equal(t, expected, m)
+			}
+		})
+	}
+}
+
+func TestResetPool(t *testing.T) {
+	pool := sync.Pool{
+		New: func() any { return NewParserPitr(nil) },
+	}
+
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Go(func() {
+			for range 10 {
+				m := make(map[string]any)
+				p := pool.Get().(*ParserPitr)
+				p.Reset(func(k string, v any) bool {
+					m[k] = v
+					return true
+				})
+
+				err := p.Parse(strings.NewReader(testJson))
+				pool.Put(p)
+
+				require.NoError(t, err)
+				require.Equal(t, expected, m)
+			}
+		})
+	}
+	wg.Wait()
+}
+
+func TestResetAllocations(t *testing.T) {
+	emitter := func(k string, v any) bool { return true }
+	r := strings.NewReader(testJson)
+
+	fresh := testing.AllocsPerRun(10, func() {
+		r.Reset(testJson)
+		p := NewParserPitr(emitter)
+		_ = p.Parse(r)
+	})
+
+	p := NewParserPitr(emitter)
+	reused := testing.AllocsPerRun(10, func() {
+		r.Reset(testJson)
+		p.Reset(emitter)
+		_ = p.Parse(r)
+	})
+
+	require.Less(t, reused, fresh)
+}
+
+func TestParseWithoutReset(t *testing.T) {
+	for name, f := range flatteners {
+		t.Run(name, func(t *testing.T) {
+			var pairs []pair
+			emitter := func(k string, v any) bool {
+				pairs = append(pairs, pair{Key: k, Value: v})
+				return true
+			}
+
+			// the state of the previous document is not kept by Parse
+			p := f(emitter, WithStrict(true), WithMaxValues(1))
+			for range 2 {
+				pairs = nil
+				err := p.Parse(strings.NewReader(`{"a": 1}`))
+				require.NoError(t, err)
+				require.Equal(t, []pair{{Key: "a", Value: float64(1)}}, pairs)
+			}
+
+			p = f(emitter)
+			err := p.Parse(strings.NewReader(`{"a": {"x": `))
+			require.ErrorIs(t, err, ErrTruncated)
+
+			pairs = nil
+			err = p.Parse(strings.NewReader(`{"b": 2}`))
+			require.NoError(t, err)
+			require.Equal(t, []pair{{Key: "b", Value: float64(2)}}, pairs)
+		})
+	}
+}
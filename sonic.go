@@ -0,0 +1,245 @@
+//go:build sonic
+
+package jsonflatten
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/bytedance/sonic/ast"
+)
+
+// Sonic flattens json documents with the sonic library. The input is read
+// in memory, each document is found by the sonic searcher and walked with
+// ast.Preorder, and the values are flattened by the same engine as the
+// other flatteners. It is only built with the sonic build tag as sonic does
+// not support every Go version.
+type Sonic struct {
+	commonParser
+
+	tok sonicTokenizer
+}
+
+// NewSonic creates a new flattener that uses sonic. If emitter is nil the
+// values are printed.
+func NewSonic(emitter Emitter, opts ...Option) *Sonic {
+	return &Sonic{
+		commonParser: newCommonParser(emitter, opts),
+	}
+}
+
+// Parse json and call the provided emitter for each value.
+func (s *Sonic) Parse(r io.Reader) error {
+	r, err := s.decoderInput(r)
+	if err != nil {
+		return err
+	}
+
+	// the data is not reused, so the strings of the values can point to it
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	s.tok.reset(unsafeString(data), s.options.utf8)
+	return s.flatten(&s.tok)
+}
+
+// ParseBytes flattens the json document in b.
+func (s *Sonic) ParseBytes(b []byte) error {
+	return s.Parse(bytes.NewReader(b))
+}
+
+// ParseFile flattens the json document in the file at path.
+func (s *Sonic) ParseFile(path string) error {
+	return parseFile(path, s.ParseBytes)
+}
+
+// sonicEOF is the error of ast.Preorder when the input ends inside a value.
+var sonicEOF = ast.Preorder("", &sonicTokenizer{}, nil)
+
+// sonicTokenizer walks each document with sonic and returns the tokens
+// collected.
+type sonicTokenizer struct {
+	// src is the input after the last document
+	src    string
+	mode   UTF8Mode
+	tokens []token
+	pos    int
+	// err is returned after the tokens of an invalid document
+	err   error
+	valid []byte
+}
+
+func (t *sonicTokenizer) reset(src string, mode UTF8Mode) {
+	t.src = src
+	t.mode = mode
+	t.err = nil
+	clear(t.tokens)
+	t.tokens = t.tokens[:0]
+	t.pos = 0
+}
+
+func (t *sonicTokenizer) next() (token, error) {
+	if t.pos < len(t.tokens) {
+		t.pos++
+		return t.tokens[t.pos-1], nil
+	}
+	if t.err != nil {
+		return token{}, t.err
+	}
+
+	t.src = strings.TrimLeft(t.src, " \t\r\n")
+	if t.src == "" {
+		return token{}, io.EOF
+	}
+
+	clear(t.tokens)
+	t.tokens = t.tokens[:0]
+	t.pos = 0
+
+	n, err := ast.NewSearcher(t.src).GetByPath()
+	if err != nil {
+		// the values before the error are emitted like with the
+		// streaming flatteners, and a document that ends early is
+		// reported as truncated
+		perr := ast.Preorder(t.src, t, nil)
+		cerr := checkControl(t.src)
+		switch {
+		case cerr != nil:
+			t.err = cerr
+		case errors.Is(perr, ErrSyntax):
+			t.err = perr
+		case errors.Is(perr, sonicEOF):
+			t.err = io.ErrUnexpectedEOF
+		default:
+			t.err = fmt.Errorf("%w: %w", ErrSyntax, err)
+		}
+
+		return t.next()
+	}
+
+	raw, err := n.Raw()
+	if err != nil {
+		return token{}, fmt.Errorf("%w: %w", ErrSyntax, err)
+	}
+
+	doc := t.src[:len(raw)]
+	t.src = t.src[len(raw):]
+	if err := checkControl(doc); err != nil {
+		return token{}, err
+	}
+	if err := ast.Preorder(doc, t, nil); err != nil {
+		if errors.Is(err, ErrSyntax) {
+			return token{}, err
+		}
+		return token{}, fmt.Errorf("%w: %w", ErrSyntax, err)
+	}
+
+	return t.next()
+}
+
+// checkControl fails if a string in doc has a control character, sonic only
+// checks them when it validates the strings.
+func checkControl(doc string) error {
+	str, escaped := false, false
+	for i := 0; i < len(doc); i++ {
+		c := doc[i]
+		switch {
+		case escaped:
+			escaped = false
+		case !str:
+			str = c == '"'
+		case c == '\\':
+			escaped = true
+		case c == '"':
+			str = false
+		case c < ' ':
+			return fmt.Errorf("%w: invalid character %q in string",
+				ErrSyntax, c)
+		}
+	}
+
+	return nil
+}
+
+// The methods of ast.Visitor add the tokens of the document.
+
+func (t *sonicTokenizer) OnNull() error {
+	t.tokens = append(t.tokens, valueToken(nullValue))
+	return nil
+}
+
+func (t *sonicTokenizer) OnBool(v bool) error {
+	t.tokens = append(t.tokens, valueToken(boolValue(v)))
+	return nil
+}
+
+// OnString adds a string. Invalid UTF-8 is replaced here, in UTF8Reject mode
+// the input was already checked by decoderInput.
+func (t *sonicTokenizer) OnString(v string) error {
+	if !utf8.ValidString(v) {
+		b, err := validUTF8(&t.valid, []byte(v), t.mode)
+		if err != nil {
+			return err
+		}
+		v = string(b)
+	}
+
+	t.tokens = append(t.tokens, valueToken(stringValue(v)))
+	return nil
+}
+
+func (t *sonicTokenizer) OnInt64(_ int64, n json.Number) error {
+	return t.number(n)
+}
+
+func (t *sonicTokenizer) OnFloat64(_ float64, n json.Number) error {
+	return t.number(n)
+}
+
+// number adds a number checked and parsed from its text, so invalid, big
+// and out of range numbers are handled like with the other tokenizers.
+func (t *sonicTokenizer) number(n json.Number) error {
+	if !validNumber([]byte(n)) {
+		return fmt.Errorf("%w: invalid number %q", ErrSyntax, n)
+	}
+
+	v, err := strconv.ParseFloat(string(n), 64)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrSyntax, err)
+	}
+
+	t.tokens = append(t.tokens, valueToken(numberValue(v)))
+	return nil
+}
+
+func (t *sonicTokenizer) OnObjectBegin(int) error {
+	t.tokens = append(t.tokens, objectStart)
+	return nil
+}
+
+func (t *sonicTokenizer) OnObjectKey(key string) error {
+	return t.OnString(key)
+}
+
+func (t *sonicTokenizer) OnObjectEnd() error {
+	t.tokens = append(t.tokens, objectEnd)
+	return nil
+}
+
+func (t *sonicTokenizer) OnArrayBegin(int) error {
+	t.tokens = append(t.tokens, arrayStart)
+	return nil
+}
+
+func (t *sonicTokenizer) OnArrayEnd() error {
+	t.tokens = append(t.tokens, arrayEnd)
+	return nil
+}
@@ -0,0 +1,32 @@
+//go:build sonic
+
+package jsonflatten
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	flatteners["sonic"] = func(e Emitter, o ...Option) flattener {
+		return NewSonic(e, o...)
+	}
+}
+
+func TestMapSonic(t *testing.T) {
+	r := strings.NewReader(testJson)
+	m := make(map[string]any)
+
+	emitter := func(k string, v any) bool {
+		m[k] = v
+		return true
+	}
+	p := NewSonic(emitter)
+
+	err := p.Parse(r)
+	require.NoError(t, err)
+
+	require.Equal(t, expected, m)
+}
@@ -1,7 +1,5 @@
 package jsonflatten
 
-import "strconv"
-
 type Type int
 type Types []Type
 
@@ -31,26 +29,40 @@ const (
 	TypeArray
 )
 
+func (t Type) String() string {
+	switch t {
+	case TypeObject:
+		return "object"
+	case TypeArray:
+		return "array"
+	default:
+		return "unknown"
+	}
+}
+
 type State struct {
-	path         path
+	prefix       int
 	jsonType     Type
-	key          string
+	keyLen       int
+	hasKey       bool
 	arrayCounter int
+	wildcard     bool
+	// gron writes the keys with gron syntax
+	gron bool
+
+	// seen, spans, skip and duplicated are used to handle repeated keys
+	seen       map[string]int
+	spans      []span
+	skip       bool
+	duplicated bool
 }
 
-func NewState(t Type, p path) State {
-	key := ""
-	if t == TypeArray {
-		key = "0"
-	}
-
-	if len(p) == 0 {
-		p = make(path, 0, 64)
-	}
+// NewState creates the state of a container whose keys start with the
+// first prefix bytes of the path buffer.
+func NewState(t Type, prefix int) State {
 	return State{
 		jsonType: t,
-		path:     p,
-		key:      key,
+		prefix:   prefix,
 	}
 }
 
@@ -59,32 +71,26 @@ func (s *State) advance() {
 		return
 	}
 
+	s.skip = false
+
 	switch s.jsonType {
 	case TypeObject:
-		s.key = ""
+		s.keyLen = 0
+		s.hasKey = false
 	case TypeArray:
 		s.arrayCounter++
-		s.key = strconv.Itoa(s.arrayCounter)
 	}
 }
 
 type States []State
 
-func (p *States) pushState(t Type) {
-	var path path
-	var key string
-
-	s := p.lastState()
-	if s != nil {
-		path = s.path
-		key = s.key
-	}
-
-	if key != "" {
-		path = append(path, key)
-	}
+func (p *States) pushState(t Type, prefix int) {
+	*p = append(*p, NewState(t, prefix))
+}
 
-	*p = append(*p, NewState(t, path))
+// reset empties the stack keeping the allocated states.
+func (p *States) reset() {
+	*p = (*p)[:0]
 }
 
 func (p *States) popState() State {
@@ -0,0 +1,73 @@
+package jsonflatten
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+var (
+	// ErrTruncated is returned when the input ends before the document is
+	// complete.
+	ErrTruncated = errors.New("truncated document")
+	// ErrTrailingData is returned in strict mode when there is more data
+	// after the root value.
+	ErrTrailingData = errors.New("trailing data after document")
+)
+
+// WithStrict makes the parser fail with ErrTrailingData if there is
+// anything but whitespace after the root value, and with ErrTruncated if
+// the input does not have a root value. By default several concatenated
+// documents are flattened one after the other and empty input is valid.
+func WithStrict(strict bool) Option {
+	return func(o *options) {
+		o.strict = strict
+	}
+}
+
+// checkTrailing fails in strict mode when a new value starts after the root
+// one ended.
+func (p *commonParser) checkTrailing() error {
+	if p.trailing() {
+		return ErrTrailingData
+	}
+
+	return nil
+}
+
+// trailing returns true in strict mode when the root value already ended.
+func (p *commonParser) trailing() bool {
+	return p.options.strict && p.done && len(p.States) == 0
+}
+
+// finish converts the error returned by the tokenizer when the input ends. A
+// clean end of the input is only valid when there are no open objects or
+// arrays, and in strict mode when the root value was read. Invalid data
+// after the root value fails with ErrTrailingData in strict mode.
+func (p *commonParser) finish(err error) error {
+	eof := errors.Is(err, io.EOF)
+	unexpected := errors.Is(err, io.ErrUnexpectedEOF)
+
+	if !eof && !unexpected {
+		if p.trailing() {
+			return fmt.Errorf("%w: %w", ErrTrailingData, err)
+		}
+		return err
+	}
+
+	if len(p.States) > 0 {
+		s := p.lastState()
+		return fmt.Errorf("%w: %s not closed at %q", ErrTruncated,
+			s.jsonType, p.path.of(s, p.options.keySeparator()))
+	}
+
+	if unexpected {
+		return fmt.Errorf("%w: %w", ErrTruncated, err)
+	}
+
+	if p.options.strict && !p.done {
+		return fmt.Errorf("%w: empty document", ErrTruncated)
+	}
+
+	return nil
+}
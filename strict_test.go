@@ -0,0 +1,70 @@
+package jsonflatten
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTruncated(t *testing.T) {
+	tests := []struct {
+		doc  string
+		path string
+	}{
+		{doc: `{"a": {"b": [1, 2`, path: `array not closed at "a.b"`},
+		{doc: `{"a": {"b": `, path: `object not closed at "a"`},
+		{doc: `[{"a": "unfinished`},
+		{doc: `{`},
+	}
+
+	for _, test := range tests {
+		for name, f := range flatteners {
+			t.Run(test.doc+"/"+name, func(t *testing.T) {
+				_, err := collect(t, f, test.doc)
+				require.ErrorIs(t, err, ErrTruncated)
+				if test.path != "" {
+					require.ErrorContains(t, err, test.path)
+				}
+			})
+		}
+	}
+}
+
+func TestStrict(t *testing.T) {
+	doc := `{"a": 1} {"b": 2}` + "\n"
+
+	for name, f := range flatteners {
+		t.Run(name, func(t *testing.T) {
+			pairs, err := collect(t, f, doc)
+			require.NoError(t, err)
+			require.Equal(t, []pair{{"a", float64(1)}, {"b", float64(2)}}, pairs)
+
+			_, err = collect(t, f, doc, WithStrict(true))
+			require.ErrorIs(t, err, ErrTrailingData)
+
+			_, err = collect(t, f, "{\"a\": 1}\n\t ", WithStrict(true))
+			require.NoError(t, err)
+
+			for _, trailing := range []string{"1", `"x"`, "true", "null", "garbage", "]"} {
+				_, err = collect(t, f, `{"a": 1} `+trailing, WithStrict(true))
+				require.ErrorIs(t, err, ErrTrailingData, trailing)
+			}
+
+			// bytes that do not start a value are also a syntax error
+			for _, invalid := range []string{`[1]}`, `[1],`, `[1] x`, `{"a": 1}]`} {
+				_, err = collect(t, f, invalid, WithStrict(true))
+				require.ErrorIs(t, err, ErrTrailingData, invalid)
+				require.ErrorIs(t, err, ErrSyntax, invalid)
+			}
+
+			// a document without root value is truncated
+			for _, empty := range []string{"", " \n\t"} {
+				_, err = collect(t, f, empty)
+				require.NoError(t, err)
+
+				_, err = collect(t, f, empty, WithStrict(true))
+				require.ErrorIs(t, err, ErrTruncated)
+			}
+		})
+	}
+}
@@ -0,0 +1,164 @@
+package jsonflatten
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/go-json-experiment/json/jsontext"
+)
+
+// ErrSyntax is returned by all the flatteners when the input is not valid
+// json. The error of the tokenizer, if there is one, is wrapped too.
+var ErrSyntax = errors.New("syntax error")
+
+// expect is the kind of token the grammar accepts next.
+type expect int
+
+const (
+	// expectValue is the state at the start of a document, after a colon
+	// and after a comma in an array.
+	expectValue expect = iota
+	// expectValueOrEnd is the state after an array starts.
+	expectValueOrEnd
+	// expectKey is the state after a comma in an object.
+	expectKey
+	// expectKeyOrEnd is the state after an object starts.
+	expectKeyOrEnd
+	// expectColon is the state after an object key.
+	expectColon
+	// expectCommaOrEnd is the state after a value inside a container.
+	expectCommaOrEnd
+)
+
+// grammar checks the order of the json tokens for the tokenizers that do
+// not do it themselves. Each method returns false when the token is not
+// valid in the current state.
+type grammar struct {
+	expect expect
+	stack  []Type
+}
+
+func (g *grammar) reset() {
+	g.expect = expectValue
+	g.stack = g.stack[:0]
+}
+
+// open checks the start of an object or array.
+func (g *grammar) open(t Type) bool {
+	if !g.expectsValue() {
+		return false
+	}
+
+	g.stack = append(g.stack, t)
+	if t == TypeObject {
+		g.expect = expectKeyOrEnd
+	} else {
+		g.expect = expectValueOrEnd
+	}
+
+	return true
+}
+
+// close checks the end of an object or array.
+func (g *grammar) close(t Type) bool {
+	start := expectKeyOrEnd
+	if t == TypeArray {
+		start = expectValueOrEnd
+	}
+
+	if len(g.stack) == 0 || g.stack[len(g.stack)-1] != t ||
+		(g.expect != expectCommaOrEnd && g.expect != start) {
+		return false
+	}
+
+	g.stack = g.stack[:len(g.stack)-1]
+	g.valueDone()
+
+	return true
+}
+
+func (g *grammar) comma() bool {
+	if g.expect != expectCommaOrEnd {
+		return false
+	}
+
+	g.expect = expectValue
+	if g.stack[len(g.stack)-1] == TypeObject {
+		g.expect = expectKey
+	}
+
+	return true
+}
+
+func (g *grammar) colon() bool {
+	if g.expect != expectColon {
+		return false
+	}
+
+	g.expect = expectValue
+	return true
+}
+
+// text checks a string, it is an object key or a value.
+func (g *grammar) text() bool {
+	if g.expect == expectKey || g.expect == expectKeyOrEnd {
+		g.expect = expectColon
+		return true
+	}
+
+	return g.value()
+}
+
+// value checks a scalar value.
+func (g *grammar) value() bool {
+	if !g.expectsValue() {
+		return false
+	}
+
+	g.valueDone()
+	return true
+}
+
+func (g *grammar) expectsValue() bool {
+	return g.expect == expectValue || g.expect == expectValueOrEnd
+}
+
+// valueDone sets the next expected token after a complete value.
+func (g *grammar) valueDone() {
+	if len(g.stack) == 0 {
+		g.expect = expectValue
+	} else {
+		g.expect = expectCommaOrEnd
+	}
+}
+
+// unexpected returns the syntax error of a token that is not valid in the
+// current state.
+func unexpected(token string) error {
+	return fmt.Errorf("%w: unexpected %s", ErrSyntax, token)
+}
+
+// syntaxError wraps in ErrSyntax the errors of the encoding/json and
+// jsontext decoders for invalid input. Truncated input and the errors of
+// the reader are returned as they are.
+func syntaxError(err error) error {
+	var (
+		serr *json.SyntaxError
+		terr *jsontext.SyntacticError
+		uerr *json.UnmarshalTypeError
+		nerr *strconv.NumError
+	)
+
+	switch {
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return err
+	case errors.As(err, &serr), errors.As(err, &terr),
+		errors.As(err, &uerr), errors.As(err, &nerr):
+		return fmt.Errorf("%w: %w", ErrSyntax, err)
+	default:
+		return err
+	}
+}
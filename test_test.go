@@ -1,7 +1,8 @@
 package jsonflatten
 
 import (
-	"os"
+	"bytes"
+	"io"
 	"slices"
 	"strings"
 	"testing"
@@ -87,7 +88,6 @@ func TestPrintV2(t *testing.T) {
 	// t.Skip()
 	r := strings.NewReader(testJson)
 	p := new(ParserV2)
-	p.emitter = p.print
 	err := p.Parse(r)
 	require.NoError(t, err)
 }
@@ -209,48 +209,82 @@ func TestMapMemoryV2(t *testing.T) {
 	require.Equal(t, expected, m)
 }
 
-func TestMapSonic(t *testing.T) {
-	r := strings.NewReader(testJson)
-	m := make(map[string]any)
-
-	emitter := func(k string, v any) bool {
-		m[k] = v
-		return true
-	}
-	p := NewSonic(emitter)
-
-	err := p.Parse(r)
-	require.NoError(t, err)
-
-	require.Equal(t, expected, m)
-}
 func TestLarge(t *testing.T) {
 	t.Skip()
-	f, err := os.Open("large-file.json")
-	require.NoError(t, err)
+	f := bytes.NewReader(bigDoc())
 
 	p := new(Parser)
-	err = p.Parse(f)
+	err := p.Parse(f)
 	require.NoError(t, err)
 }
 
 func TestLargeV2(t *testing.T) {
 	// t.Skip()
-	f, err := os.Open("large-file.json")
-	require.NoError(t, err)
+	f := bytes.NewReader(bigDoc())
 
 	p := new(ParserV2)
 	p.emitter = func(s string, a any) bool { return true }
-	err = p.Parse(f)
+	err := p.Parse(f)
 	require.NoError(t, err)
 }
 
 func TestLargePitr(t *testing.T) {
-	f, err := os.Open("large-file.json")
-	require.NoError(t, err)
+	f := bytes.NewReader(bigDoc())
 
 	p := new(ParserPitr)
 	p.emitter = func(s string, a any) bool { return true }
-	err = p.Parse(f)
+	err := p.Parse(f)
 	require.NoError(t, err)
 }
+
+type flattener interface {
+	Parse(io.Reader) error
+}
+
+var flatteners = map[string]func(Emitter, ...Option) flattener{
+	"v1":       func(e Emitter, o ...Option) flattener { return NewParser(e, o...) },
+	"v2":       func(e Emitter, o ...Option) flattener { return NewParserV2(e, o...) },
+	"pitr":     func(e Emitter, o ...Option) flattener { return NewParserPitr(e, o...) },
+	"fast":     func(e Emitter, o ...Option) flattener { return NewParserFast(e, o...) },
+	"memory":   func(e Emitter, o ...Option) flattener { return NewMemory(e, o...) },
+	"memoryv2": func(e Emitter, o ...Option) flattener { return NewMemoryV2(e, o...) },
+	"parallel": func(e Emitter, o ...Option) flattener {
+		// one element per chunk to test the split of small documents
+		p := NewParallel(e, o...)
+		p.chunkSize = 1
+		return p
+	},
+}
+
+type pair struct {
+	Key   string
+	Value any
+}
+
+// collect flattens doc and returns the emitted pairs sorted by key.
+func collect(
+	t testing.TB,
+	f func(Emitter, ...Option) flattener,
+	doc string,
+	opts ...Option,
+) ([]pair, error) {
+	t.Helper()
+
+	var pairs []pair
+	p := f(func(k string, v any) bool {
+		pairs = append(pairs, pair{Key: k, Value: v})
+		return true
+	}, opts...)
+
+	err := p.Parse(strings.NewReader(doc))
+	sortPairs(pairs)
+
+	return pairs, err
+}
+
+// sortPairs sorts the pairs by key keeping the order of repeated keys.
+func sortPairs(pairs []pair) {
+	slices.SortStableFunc(pairs, func(a, b pair) int {
+		return strings.Compare(a.Key, b.Key)
+	})
+}
@@ -0,0 +1,103 @@
+package jsonflatten
+
+import (
+	"errors"
+	"strings"
+)
+
+// tokenKind is the type of a json token.
+type tokenKind int
+
+const (
+	tokenObjectStart tokenKind = iota
+	tokenObjectEnd
+	tokenArrayStart
+	tokenArrayEnd
+	// tokenValue is a string, number, bool or null, including object keys.
+	tokenValue
+)
+
+// token is a json token read by a tokenizer.
+type token struct {
+	kind  tokenKind
+	value Value
+	// borrowed is set when the string of value shares memory with the
+	// tokenizer buffer and is only valid until the next token.
+	borrowed bool
+}
+
+var (
+	objectStart = token{kind: tokenObjectStart}
+	objectEnd   = token{kind: tokenObjectEnd}
+	arrayStart  = token{kind: tokenArrayStart}
+	arrayEnd    = token{kind: tokenArrayEnd}
+)
+
+func valueToken(v Value) token {
+	return token{kind: tokenValue, value: v}
+}
+
+func borrowedToken(s string) token {
+	return token{kind: tokenValue, value: stringValue(s), borrowed: true}
+}
+
+// tokenizer is implemented by the json tokenizers used by the flatteners.
+// Object keys are returned as string values, the flattener knows they are
+// keys by its state.
+type tokenizer interface {
+	// next returns the next token. At the end of the input it returns
+	// io.EOF, or io.ErrUnexpectedEOF if it ends inside a token.
+	next() (token, error)
+}
+
+// flatten reads all the tokens of t and calls the emitter for each value.
+// This is the state machine shared by all the flatteners.
+func (p *commonParser) flatten(t tokenizer) error {
+	p.begin()
+
+	for {
+		tok, err := t.next()
+		if err != nil {
+			return p.flush(p.finish(err))
+		}
+
+		switch tok.kind {
+		case tokenObjectStart:
+			err = p.openContainer(TypeObject)
+		case tokenObjectEnd:
+			err = p.closeContainer(TypeObject)
+		case tokenArrayStart:
+			err = p.openContainer(TypeArray)
+		case tokenArrayEnd:
+			err = p.closeContainer(TypeArray)
+		default:
+			err = p.valueToken(tok)
+		}
+
+		if err != nil {
+			if errors.Is(err, errExit) {
+				return p.flush(nil)
+			}
+			return p.flush(err)
+		}
+	}
+}
+
+// valueToken handles a scalar token. Strings are object keys or values
+// depending on the current state.
+func (p *commonParser) valueToken(t token) error {
+	v := t.value
+	if v.Kind != KindString {
+		return p.commonEmitter(v)
+	}
+
+	if p.isKey() {
+		return p.keyToken(v.Str)
+	}
+
+	if t.borrowed && p.options.rawEmitter == nil {
+		v.Str = strings.Clone(v.Str)
+	}
+
+	return p.stringToken(v.Str)
+}
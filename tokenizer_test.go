@@ -0,0 +1,86 @@
+package jsonflatten
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/go-json-experiment/json/jsontext"
+	"github.com/stretchr/testify/require"
+	"pitr.ca/jsontokenizer"
+)
+
+var tokenizers = map[string]func(io.Reader) tokenizer{
+	"v1": func(r io.Reader) tokenizer {
+		return &jsonTokenizer{dec: json.NewDecoder(r)}
+	},
+	"v2": func(r io.Reader) tokenizer {
+		return &jsontextTokenizer{dec: jsontext.NewDecoder(r)}
+	},
+	"pitr": func(r io.Reader) tokenizer {
+		return &pitrTokenizer{dec: jsontokenizer.NewWithSize(r, readSize)}
+	},
+	"fast": func(r io.Reader) tokenizer {
+		t := new(fastTokenizer)
+		t.reset(r)
+		return t
+	},
+	"memory": func(r io.Reader) tokenizer {
+		t := new(nodeTokenizer)
+		t.reset(json.NewDecoder(r), &internTable{}, 0)
+		return t
+	},
+}
+
+// tokens reads all the tokens of doc copying the borrowed strings.
+func tokens(t *testing.T, tok tokenizer) []token {
+	t.Helper()
+
+	var list []token
+	for {
+		tk, err := tok.next()
+		if errors.Is(err, io.EOF) {
+			return list
+		}
+		require.NoError(t, err)
+
+		if tk.borrowed {
+			tk.value.Str = strings.Clone(tk.value.Str)
+			tk.borrowed = false
+		}
+		list = append(list, tk)
+	}
+}
+
+func TestTokenizers(t *testing.T) {
+	doc := `{"a": {"b\n": ["c", 1.5, true, false, null, [], {}]}, "": ""}
+		[{"d": -1e3}] "e" 2`
+
+	expected := tokens(t, tokenizers["v1"](strings.NewReader(doc)))
+	require.Len(t, expected, 27)
+
+	for name, f := range tokenizers {
+		t.Run(name, func(t *testing.T) {
+			list := tokens(t, f(strings.NewReader(doc)))
+			require.Equal(t, expected, list)
+		})
+	}
+}
+
+func TestRootValues(t *testing.T) {
+	for name, f := range flatteners {
+		t.Run(name, func(t *testing.T) {
+			pairs, err := collect(t, f, " \n")
+			require.NoError(t, err)
+			require.Empty(t, pairs)
+
+			_, err = collect(t, f, `"a"`)
+			require.Error(t, err)
+
+			_, err = collect(t, f, `1`)
+			require.Error(t, err)
+		})
+	}
+}
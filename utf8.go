@@ -0,0 +1,260 @@
+package jsonflatten
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// UTF8Mode selects what to do with invalid UTF-8 and lone surrogate escapes
+// in keys and string values.
+type UTF8Mode int
+
+const (
+	// UTF8Replace changes each invalid byte or lone surrogate with the
+	// Unicode replacement character U+FFFD. This is the default.
+	UTF8Replace UTF8Mode = iota
+	// UTF8Reject stops parsing with ErrInvalidUTF8.
+	UTF8Reject
+	// UTF8PassThrough keeps the invalid bytes as they are and converts lone
+	// surrogates to their WTF-8 bytes. Parser, Memory and MemoryV2 use the
+	// encoding/json decoder that can not keep invalid UTF-8, they fail with
+	// errors.ErrUnsupported.
+	UTF8PassThrough
+)
+
+// ErrInvalidUTF8 is returned when the input contains invalid UTF-8 or lone
+// surrogates and the mode is UTF8Reject.
+var ErrInvalidUTF8 = errors.New("invalid UTF-8")
+
+// errPassThrough is returned in UTF8PassThrough mode by the flatteners whose
+// decoder replaces invalid UTF-8, like the encoding/json one.
+var errPassThrough = fmt.Errorf(
+	"%w: UTF8PassThrough with a decoder that replaces invalid UTF-8",
+	errors.ErrUnsupported)
+
+// WithInvalidUTF8 sets what to do with invalid UTF-8 and lone surrogates.
+func WithInvalidUTF8(mode UTF8Mode) Option {
+	return func(o *options) {
+		o.utf8 = mode
+	}
+}
+
+const utf8Chunk = 4 * 1024
+
+// utf8Reader fails with ErrInvalidUTF8 when the input has invalid UTF-8 or
+// lone surrogate escapes. It is used in UTF8Reject mode by the flatteners
+// that use the encoding/json decoder, as it replaces them. The other
+// tokenizers check the strings themselves with validUTF8.
+type utf8Reader struct {
+	r io.Reader
+
+	in         []byte
+	start, end int
+	rerr       error
+
+	out []byte
+	pos int
+	err error
+
+	offset int64
+}
+
+func newUTF8Reader(r io.Reader) *utf8Reader {
+	return &utf8Reader{
+		r:   r,
+		in:  make([]byte, utf8Chunk),
+		out: make([]byte, 0, utf8Chunk),
+	}
+}
+
+// reset reuses the buffers to read from r.
+func (u *utf8Reader) reset(r io.Reader) {
+	*u = utf8Reader{
+		r:   r,
+		in:  u.in,
+		out: u.out[:0],
+	}
+}
+
+func (u *utf8Reader) Read(b []byte) (int, error) {
+	for u.pos == len(u.out) {
+		if u.err != nil {
+			return 0, u.err
+		}
+
+		u.out = u.out[:0]
+		u.pos = 0
+		u.fill()
+	}
+
+	n := copy(b, u.out[u.pos:])
+	u.pos += n
+
+	return n, nil
+}
+
+func (u *utf8Reader) fill() {
+	if u.rerr == nil {
+		// keep the bytes not processed yet, they may be an incomplete
+		// sequence
+		n := copy(u.in, u.in[u.start:u.end])
+		u.start = 0
+		u.end = n
+
+		n, err := u.r.Read(u.in[u.end:])
+		u.end += n
+		u.rerr = err
+	}
+
+	eof := u.rerr != nil
+	in := u.in[:u.end]
+	i := u.start
+	run := i
+
+loop:
+	for i < len(in) {
+		c := in[i]
+		if c < utf8.RuneSelf && c != '\\' {
+			i++
+			continue
+		}
+
+		u.out = append(u.out, in[run:i]...)
+		run = i
+
+		if c == '\\' {
+			n, err := u.escape(in[i:], i, eof)
+			if err != nil {
+				u.err = err
+				return
+			}
+			if n == 0 {
+				break loop
+			}
+
+			i += n
+			run = i
+			continue
+		}
+
+		if !eof && !utf8.FullRune(in[i:]) {
+			break loop
+		}
+
+		r, size := utf8.DecodeRune(in[i:])
+		if r == utf8.RuneError && size == 1 {
+			u.err = u.invalid(i)
+			return
+		}
+
+		u.out = append(u.out, in[i:i+size]...)
+		i += size
+		run = i
+	}
+
+	u.out = append(u.out, in[run:i]...)
+	u.offset += int64(i - u.start)
+	u.start = i
+
+	if eof && u.start == u.end {
+		u.err = u.rerr
+	}
+}
+
+// escape writes the escape sequence at the start of b, found at pos in the
+// input buffer, and returns the number of bytes used or 0 if it needs more
+// data.
+func (u *utf8Reader) escape(b []byte, pos int, eof bool) (int, error) {
+	if len(b) < 2 || (b[1] == 'u' && len(b) < 6) {
+		if !eof {
+			return 0, nil
+		}
+
+		// incomplete escape, let the tokenizer fail
+		u.out = append(u.out, b...)
+		return len(b), nil
+	}
+
+	if b[1] != 'u' {
+		u.out = append(u.out, b[:2]...)
+		return 2, nil
+	}
+
+	r, ok := parseHex(b[2:6])
+	if !ok || !utf16.IsSurrogate(r) {
+		u.out = append(u.out, b[:6]...)
+		return 6, nil
+	}
+
+	if r < 0xdc00 {
+		if len(b) < 12 && !eof {
+			return 0, nil
+		}
+
+		if len(b) >= 12 && b[6] == '\\' && b[7] == 'u' {
+			low, ok := parseHex(b[8:12])
+			if ok && low >= 0xdc00 && low <= 0xdfff {
+				u.out = append(u.out, b[:12]...)
+				return 12, nil
+			}
+		}
+	}
+
+	return 0, u.invalid(pos)
+}
+
+func (u *utf8Reader) invalid(pos int) error {
+	return fmt.Errorf("%w at offset %d", ErrInvalidUTF8,
+		u.offset+int64(pos-u.start))
+}
+
+// validUTF8 applies mode to the string b read by a tokenizer, after its
+// escape sequences are decoded. It returns b when it is valid UTF-8 or the
+// mode is UTF8PassThrough. With UTF8Replace each invalid byte is replaced
+// with U+FFFD in a copy stored in buf, that must not share memory with b.
+func validUTF8(buf *[]byte, b []byte, mode UTF8Mode) ([]byte, error) {
+	if mode == UTF8PassThrough || utf8.Valid(b) {
+		return b, nil
+	}
+
+	out := (*buf)[:0]
+	for len(b) > 0 {
+		r, size := utf8.DecodeRune(b)
+		if r == utf8.RuneError && size == 1 {
+			if mode == UTF8Reject {
+				return nil, fmt.Errorf("%w: byte %#x in string", ErrInvalidUTF8,
+					b[0])
+			}
+			out = utf8.AppendRune(out, utf8.RuneError)
+		} else {
+			out = append(out, b[:size]...)
+		}
+
+		b = b[size:]
+	}
+	*buf = out
+
+	return out, nil
+}
+
+func parseHex(b []byte) (rune, bool) {
+	var r rune
+	for _, c := range b {
+		switch {
+		case c >= '0' && c <= '9':
+			c -= '0'
+		case c >= 'a' && c <= 'f':
+			c = c - 'a' + 10
+		case c >= 'A' && c <= 'F':
+			c = c - 'A' + 10
+		default:
+			return 0, false
+		}
+		r = r<<4 | rune(c)
+	}
+
+	return r, true
+}
@@ -0,0 +1,86 @@
+package jsonflatten
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/require"
+)
+
+const invalidUTF8Json = "{\"a\xffb\": \"c\xfe\", \"lone\": \"x\\ud800y\", \"pair\": \"\\ud83d\\ude00\", \"ok\": \"ñ\"}"
+
+func TestInvalidUTF8(t *testing.T) {
+	replaced := []pair{
+		{"a�b", "c�"},
+		{"lone", "x�y"},
+		{"ok", "ñ"},
+		{"pair", "\U0001F600"},
+	}
+
+	for name, f := range flatteners {
+		t.Run(name, func(t *testing.T) {
+			pairs, err := collect(t, f, invalidUTF8Json)
+			require.NoError(t, err)
+			require.Equal(t, replaced, pairs)
+
+			_, err = collect(t, f, invalidUTF8Json, WithInvalidUTF8(UTF8Reject))
+			require.ErrorIs(t, err, ErrInvalidUTF8)
+
+			_, err = collect(t, f, `{"lone": "\udc00"}`, WithInvalidUTF8(UTF8Reject))
+			require.ErrorIs(t, err, ErrInvalidUTF8)
+
+			_, err = collect(t, f, testJson, WithInvalidUTF8(UTF8Reject))
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestInvalidUTF8PassThrough(t *testing.T) {
+	kept := []pair{
+		{"a\xffb", "c\xfe"},
+		{"lone", "x\xed\xa0\x80y"},
+		{"ok", "ñ"},
+		{"pair", "\U0001F600"},
+	}
+
+	for name, f := range flatteners {
+		t.Run(name, func(t *testing.T) {
+			pairs, err := collect(t, f, invalidUTF8Json,
+				WithInvalidUTF8(UTF8PassThrough))
+
+			switch name {
+			case "v1", "memory", "memoryv2", "sonic":
+				require.ErrorIs(t, err, errors.ErrUnsupported)
+			default:
+				require.NoError(t, err)
+				require.Equal(t, kept, pairs)
+			}
+		})
+	}
+}
+
+func TestUTF8ReaderBoundaries(t *testing.T) {
+	in := strings.Repeat(`"ñé😀\\u",`, 1000)
+
+	r := newUTF8Reader(iotest.OneByteReader(strings.NewReader(in)))
+	b, err := io.ReadAll(r)
+	require.NoError(t, err)
+	require.Equal(t, in, string(b))
+
+	// sequences split between full reads
+	for n := range 16 {
+		shifted := strings.Repeat("x", n) + in
+		r = newUTF8Reader(strings.NewReader(shifted))
+		b, err = io.ReadAll(r)
+		require.NoError(t, err)
+		require.Equal(t, shifted, string(b))
+	}
+
+	r = newUTF8Reader(iotest.HalfReader(strings.NewReader(in + "\xff")))
+	_, err = io.ReadAll(r)
+	require.ErrorIs(t, err, ErrInvalidUTF8)
+	require.ErrorContains(t, err, "offset 14000")
+}
@@ -0,0 +1,126 @@
+package jsonflatten
+
+import (
+	"strings"
+	"unsafe"
+)
+
+// Kind is the type of a scalar JSON value.
+type Kind int
+
+const (
+	KindNull Kind = iota
+	KindString
+	KindNumber
+	KindBool
+)
+
+// Value is a scalar JSON value. Only the field for its Kind is set. It is
+// used instead of any to avoid allocating memory to box numbers and bools.
+type Value struct {
+	Kind Kind
+	Str  string
+	Num  float64
+	Bool bool
+}
+
+// RawEmitter is called for each value with the flattened key as bytes. The
+// key and the string value are only valid during the call as their memory
+// is reused for the next value, use strings.Clone or copy them to keep
+// them. The key must not be modified. It returns false to stop parsing.
+type RawEmitter func(key []byte, v Value) bool
+
+// WithRawEmitter calls f for each value instead of the Emitter. This avoids
+// allocating the key and boxing the value for each call.
+func WithRawEmitter(f RawEmitter) Option {
+	return func(o *options) {
+		o.rawEmitter = f
+	}
+}
+
+// TypedEmitter is called for each value with its flattened key and typed
+// value. It returns false to stop parsing.
+type TypedEmitter func(key string, v Value) bool
+
+// WithTypedEmitter calls f for each value instead of the Emitter. Unlike
+// Emitter numbers and bools are not converted to any so they do not
+// allocate memory. A RawEmitter set with WithRawEmitter takes precedence.
+func WithTypedEmitter(f TypedEmitter) Option {
+	return func(o *options) {
+		o.typedEmitter = f
+	}
+}
+
+func stringValue(s string) Value {
+	return Value{Kind: KindString, Str: s}
+}
+
+func numberValue(f float64) Value {
+	return Value{Kind: KindNumber, Num: f}
+}
+
+func boolValue(b bool) Value {
+	return Value{Kind: KindBool, Bool: b}
+}
+
+var nullValue = Value{Kind: KindNull}
+
+// Any returns the value as the types used by Emitter: string, float64, bool
+// or nil.
+func (v Value) Any() any {
+	switch v.Kind {
+	case KindString:
+		return v.Str
+	case KindNumber:
+		return v.Num
+	case KindBool:
+		return v.Bool
+	default:
+		return nil
+	}
+}
+
+// clone returns a value that does not share memory with tokenizer buffers.
+func (v Value) clone() Value {
+	if v.Kind == KindString {
+		v.Str = strings.Clone(v.Str)
+	}
+
+	return v
+}
+
+// borrow returns the bytes as a string. When a RawEmitter is used the string
+// shares memory with b and is only valid until b is modified, otherwise it
+// is a copy.
+func (p *commonParser) borrow(b []byte) string {
+	if p.options.rawEmitter == nil {
+		return string(b)
+	}
+
+	return unsafeString(b)
+}
+
+// unsafeString returns a string that shares memory with b.
+func unsafeString(b []byte) string {
+	if len(b) == 0 {
+		return ""
+	}
+
+	return unsafe.String(&b[0], len(b))
+}
+
+// call sends the key and value to the configured emitter.
+func (p *commonParser) call(key []byte, v Value) bool {
+	switch {
+	case p.options.gron != nil:
+		return p.options.gron.emit(key, v)
+	case p.options.rawEmitter != nil:
+		return p.options.rawEmitter(key, v)
+	case p.options.typedEmitter != nil:
+		return p.options.typedEmitter(string(key), v)
+	case p.emitter == nil:
+		return p.printer().EmitRaw(key, v)
+	default:
+		return p.emitter(string(key), v.Any())
+	}
+}
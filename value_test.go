@@ -0,0 +1,119 @@
+package jsonflatten
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRawEmitter(t *testing.T) {
+	for name, f := range flatteners {
+		t.Run(name, func(t *testing.T) {
+			m := make(map[string]any)
+			p := f(nil, WithRawEmitter(func(k []byte, v Value) bool {
+				m[string(k)] = v.clone().Any()
+				return true
+			}))
+
+			err := p.Parse(strings.NewReader(testJson))
+			require.NoError(t, err)
+			require.Equal(t, expected, m)
+		})
+	}
+}
+
+func TestRawEmitterAllocations(t *testing.T) {
+	doc := func(n int) string {
+		var b strings.Builder
+		b.WriteString(`{"items": [`)
+		for i := range n {
+			if i > 0 {
+				b.WriteString(",")
+			}
+			b.WriteString(`{"id": 12345, "name": "some name", "ok": true, "tags": ["a", "b"]}`)
+		}
+		b.WriteString(`]}`)
+		return b.String()
+	}
+
+	var count int
+	var sum float64
+	p := NewParserPitr(nil, WithRawEmitter(func(k []byte, v Value) bool {
+		count += len(k) + len(v.Str)
+		sum += v.Num
+		return true
+	}))
+
+	allocs := func(doc string) float64 {
+		r := strings.NewReader(doc)
+		return testing.AllocsPerRun(10, func() {
+			r.Reset(doc)
+			p.Reset(nil)
+			err := p.Parse(r)
+			require.NoError(t, err)
+		})
+	}
+
+	small := allocs(doc(10))
+	big := allocs(doc(1000))
+
+	// allocations do not depend on the number of values
+	require.Equal(t, small, big)
+}
+
+func TestTypedEmitter(t *testing.T) {
+	for name, f := range flatteners {
+		t.Run(name, func(t *testing.T) {
+			m := make(map[string]any)
+			kinds := make(map[Kind]int)
+			p := f(nil, WithTypedEmitter(func(k string, v Value) bool {
+				m[k] = v.Any()
+				kinds[v.Kind]++
+				return true
+			}))
+
+			err := p.Parse(strings.NewReader(testJson))
+			require.NoError(t, err)
+			require.Equal(t, expected, m)
+			require.Equal(t, map[Kind]int{
+				KindString: 12,
+				KindNumber: 8,
+				KindBool:   2,
+				KindNull:   2,
+			}, kinds)
+		})
+	}
+}
+
+func TestTypedEmitterAllocations(t *testing.T) {
+	doc := `[` + strings.Repeat(`1.5, true, `, 100) + `2]`
+	r := strings.NewReader(doc)
+
+	measure := func(p *ParserPitr) float64 {
+		return testing.AllocsPerRun(10, func() {
+			r.Reset(doc)
+			p.Reset(nil)
+			err := p.Parse(r)
+			require.NoError(t, err)
+		})
+	}
+
+	var keys int
+	typed := measure(NewParserPitr(nil, WithTypedEmitter(
+		func(k string, v Value) bool {
+			keys += len(k)
+			return true
+		},
+	)))
+
+	var values []any
+	boxed := measure(NewParserPitr(nil, WithTypedEmitter(
+		func(k string, v Value) bool {
+			values = append(values[:0], v.Any())
+			return true
+		},
+	)))
+
+	require.Less(t, typed, boxed)
+}
@@ -0,0 +1,380 @@
+package jsonflatten
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"unicode/utf16"
+)
+
+// Format is the output format of a Writer.
+type Format int
+
+const (
+	// FormatJSON writes a flat JSON object with a member per value. The
+	// values are written as they are emitted, so a key emitted more than
+	// once, with ArrayWildcard, DuplicateAll or several documents, is
+	// written once per value instead of merged into an array. Decoders
+	// like encoding/json keep the last one.
+	FormatJSON Format = iota
+	// FormatCSV writes a "key,value" header and a CSV record per value.
+	FormatCSV
+	// FormatTSV writes a "key<tab>value" header and a line per value.
+	// Tabs, new lines and backslashes are escaped with a backslash.
+	FormatTSV
+	// FormatDotenv writes a KEY=value line per value. The key is converted
+	// to upper case with "_" instead of other characters, glossary.title is
+	// written as GLOSSARY_TITLE, and strings are double quoted.
+	FormatDotenv
+	// FormatProperties writes a Java properties key=value line per value.
+	// Characters outside ASCII are escaped as \uXXXX.
+	FormatProperties
+	// FormatText writes a "key = value" line per value with the value
+	// encoded as JSON.
+	FormatText
+)
+
+// Writer writes flattened values to an io.Writer in one of the formats. Its
+// Emit, EmitTyped and EmitRaw methods are used as the emitter and Close
+// writes the end of the output and flushes it:
+//
+//	w := jsonflatten.NewWriter(os.Stdout, jsonflatten.FormatCSV)
+//	p := jsonflatten.NewParserFast(nil, jsonflatten.WithRawEmitter(w.EmitRaw))
+//	err := p.Parse(r)
+//	if cerr := w.Close(); err == nil {
+//		err = cerr
+//	}
+//
+// Strings are quoted in JSON, text and dotenv, the other formats write them
+// as is with their own escaping. Null is written as null, except in dotenv
+// and properties where the value is empty.
+type Writer struct {
+	// Number appends a number to dst. By default numbers are formatted
+	// like encoding/json. It can be changed before the first value.
+	Number func(dst []byte, f float64) []byte
+
+	w      *bufio.Writer
+	format Format
+	line   []byte
+	text   []byte
+	count  int
+	err    error
+}
+
+// NewWriter creates a Writer that writes to w in the given format.
+func NewWriter(w io.Writer, format Format) *Writer {
+	return &Writer{
+		Number: AppendNumber,
+		w:      bufio.NewWriter(w),
+		format: format,
+	}
+}
+
+// Emit writes a value. It is an Emitter.
+func (w *Writer) Emit(key string, v any) bool {
+	return w.EmitTyped(key, valueOf(v))
+}
+
+// EmitTyped writes a value. It is a TypedEmitter.
+func (w *Writer) EmitTyped(key string, v Value) bool {
+	return w.write(key, v)
+}
+
+// EmitRaw writes a value. It is a RawEmitter.
+func (w *Writer) EmitRaw(key []byte, v Value) bool {
+	return w.write(unsafeString(key), v)
+}
+
+// WithOutput sets the writer where the flatteners created without emitter
+// write the values, one "key = value" line each. It is os.Stdout by
+// default. The output is buffered and flushed when Parse returns.
+func WithOutput(w io.Writer) Option {
+	return func(o *options) {
+		o.output = w
+	}
+}
+
+// NewTextEmitter creates a Writer in FormatText. Its Emit method is the
+// emitter of the flatteners created without one, see WithOutput.
+func NewTextEmitter(w io.Writer) *Writer {
+	return NewWriter(w, FormatText)
+}
+
+// Flush writes the buffered output without ending it. It returns the first
+// write error.
+func (w *Writer) Flush() error {
+	if w.err != nil {
+		return w.err
+	}
+
+	w.err = w.w.Flush()
+	return w.err
+}
+
+// Close writes the end of the output and flushes it. It returns the first
+// write error. The underlying io.Writer is not closed.
+func (w *Writer) Close() error {
+	if w.err != nil {
+		return w.err
+	}
+
+	switch w.format {
+	case FormatJSON:
+		if w.count == 0 {
+			w.w.WriteString("{}\n")
+		} else {
+			w.w.WriteString("\n}\n")
+		}
+	case FormatCSV, FormatTSV:
+		if w.count == 0 {
+			w.w.Write(w.header(nil))
+		}
+	}
+
+	return w.Flush()
+}
+
+// header appends the first line of CSV and TSV to dst.
+func (w *Writer) header(dst []byte) []byte {
+	switch w.format {
+	case FormatCSV:
+		return append(dst, "key,value\n"...)
+	case FormatTSV:
+		return append(dst, "key\tvalue\n"...)
+	default:
+		return dst
+	}
+}
+
+func (w *Writer) write(key string, v Value) bool {
+	if w.err != nil {
+		return false
+	}
+
+	b := w.line[:0]
+	if w.count == 0 {
+		b = w.header(b)
+	}
+
+	switch w.format {
+	case FormatJSON:
+		if w.count == 0 {
+			b = append(b, "{\n  "...)
+		} else {
+			b = append(b, ",\n  "...)
+		}
+		b = appendQuoted(b, key)
+		b = append(b, ": "...)
+		b = w.value(b, v, false)
+
+	case FormatCSV:
+		w.text = w.value(w.text[:0], v, true)
+		b = appendCSV(b, key)
+		b = append(b, ',')
+		b = appendCSV(b, unsafeString(w.text))
+		b = append(b, '\n')
+
+	case FormatTSV:
+		w.text = w.value(w.text[:0], v, true)
+		b = appendTSV(b, key)
+		b = append(b, '\t')
+		b = appendTSV(b, unsafeString(w.text))
+		b = append(b, '\n')
+
+	case FormatDotenv:
+		b = appendEnvKey(b, key)
+		b = append(b, '=')
+		switch v.Kind {
+		case KindString:
+			b = appendEnvString(b, v.Str)
+		case KindNumber, KindBool:
+			b = w.value(b, v, false)
+		}
+		b = append(b, '\n')
+
+	case FormatText:
+		b = append(b, key...)
+		b = append(b, " = "...)
+		b = w.value(b, v, false)
+		b = append(b, '\n')
+
+	case FormatProperties:
+		b = appendProperty(b, key, true)
+		b = append(b, '=')
+		if v.Kind != KindNull {
+			w.text = w.value(w.text[:0], v, true)
+			b = appendProperty(b, unsafeString(w.text), false)
+		}
+		b = append(b, '\n')
+	}
+
+	w.line = b
+	w.count++
+
+	if _, err := w.w.Write(b); err != nil {
+		w.err = err
+		return false
+	}
+
+	return true
+}
+
+// value appends v to dst as JSON, or with strings as text.
+func (w *Writer) value(dst []byte, v Value, text bool) []byte {
+	switch {
+	case v.Kind == KindNumber:
+		return w.Number(dst, v.Num)
+	case text:
+		return appendText(dst, v)
+	default:
+		return appendJSON(dst, v)
+	}
+}
+
+// valueOf converts a value received by an Emitter to Value. Types not
+// produced by the flatteners are formatted as strings.
+func valueOf(v any) Value {
+	switch v := v.(type) {
+	case nil:
+		return nullValue
+	case string:
+		return stringValue(v)
+	case float64:
+		return numberValue(v)
+	case bool:
+		return boolValue(v)
+	default:
+		return stringValue(fmt.Sprint(v))
+	}
+}
+
+// appendCSV appends s to dst as a CSV field. It is quoted when it contains
+// a comma, quote or new line or starts with a space, like encoding/csv.
+func appendCSV(dst []byte, s string) []byte {
+	quote := s != "" && (s[0] == ' ' || s[0] == '\t')
+	for i := 0; i < len(s) && !quote; i++ {
+		switch s[i] {
+		case ',', '"', '\r', '\n':
+			quote = true
+		}
+	}
+
+	if !quote {
+		return append(dst, s...)
+	}
+
+	dst = append(dst, '"')
+	for i := 0; i < len(s); i++ {
+		if s[i] == '"' {
+			dst = append(dst, '"')
+		}
+		dst = append(dst, s[i])
+	}
+
+	return append(dst, '"')
+}
+
+// appendTSV appends s to dst escaping tabs, new lines and backslashes.
+func appendTSV(dst []byte, s string) []byte {
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '\t':
+			dst = append(dst, `\t`...)
+		case '\n':
+			dst = append(dst, `\n`...)
+		case '\r':
+			dst = append(dst, `\r`...)
+		case '\\':
+			dst = append(dst, `\\`...)
+		default:
+			dst = append(dst, c)
+		}
+	}
+
+	return dst
+}
+
+// appendEnvKey appends key to dst as an environment variable name: upper
+// case letters, digits and "_", not starting with a digit.
+func appendEnvKey(dst []byte, key string) []byte {
+	if key == "" || key[0] >= '0' && key[0] <= '9' {
+		dst = append(dst, '_')
+	}
+
+	for i := 0; i < len(key); i++ {
+		switch c := key[i]; {
+		case c >= 'a' && c <= 'z':
+			dst = append(dst, c-'a'+'A')
+		case c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+			dst = append(dst, c)
+		default:
+			dst = append(dst, '_')
+		}
+	}
+
+	return dst
+}
+
+// appendEnvString appends s to dst double quoted. Backslashes, quotes,
+// dollar signs and control characters are escaped so shells and dotenv
+// loaders read the same string.
+func appendEnvString(dst []byte, s string) []byte {
+	dst = append(dst, '"')
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '\\', '"', '$', '`':
+			dst = append(dst, '\\', c)
+		case '\n':
+			dst = append(dst, `\n`...)
+		case '\r':
+			dst = append(dst, `\r`...)
+		case '\t':
+			dst = append(dst, `\t`...)
+		default:
+			dst = append(dst, c)
+		}
+	}
+
+	return append(dst, '"')
+}
+
+// appendProperty appends s to dst escaped as a properties key or value.
+// Keys also escape the separators and spaces, values only a leading space.
+func appendProperty(dst []byte, s string, key bool) []byte {
+	for i, r := range s {
+		switch {
+		case r == '\\':
+			dst = append(dst, `\\`...)
+		case r == '\t':
+			dst = append(dst, `\t`...)
+		case r == '\n':
+			dst = append(dst, `\n`...)
+		case r == '\r':
+			dst = append(dst, `\r`...)
+		case r == '\f':
+			dst = append(dst, `\f`...)
+		case r == ' ' && (key || i == 0),
+			key && (r == '=' || r == ':'),
+			(key || i == 0) && (r == '#' || r == '!'):
+			dst = append(dst, '\\', byte(r))
+		case r < 0x20 || r > 0x7e:
+			if r > 0xffff {
+				r1, r2 := utf16.EncodeRune(r)
+				dst = appendUnicode(dst, r1)
+				dst = appendUnicode(dst, r2)
+			} else {
+				dst = appendUnicode(dst, r)
+			}
+		default:
+			dst = append(dst, byte(r))
+		}
+	}
+
+	return dst
+}
+
+// appendUnicode appends r as a \uXXXX escape.
+func appendUnicode(dst []byte, r rune) []byte {
+	return append(dst, '\\', 'u',
+		hex[r>>12&0xf], hex[r>>8&0xf], hex[r>>4&0xf], hex[r&0xf])
+}
@@ -0,0 +1,208 @@
+package jsonflatten
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const writerDoc = `{
+	"glossary": {"title": "example glossary", "id": 42},
+	"text": "a, \"b\"\n\tc\\ $d",
+	"unicode": "é 😀",
+	"key=a:b c": " x",
+	"ok": true,
+	"none": null,
+	"1st": 1.5
+}`
+
+func TestWriter(t *testing.T) {
+	tests := []struct {
+		format   Format
+		expected string
+	}{
+		{
+			format: FormatJSON,
+			expected: `{
+  "glossary.title": "example glossary",
+  "glossary.id": 42,
+  "text": "a, \"b\"\n\tc\\ $d",
+  "unicode": "é 😀",
+  "key=a:b c": " x",
+  "ok": true,
+  "none": null,
+  "1st": 1.5
+}
+`,
+		},
+		{
+			format: FormatCSV,
+			expected: `key,value
+glossary.title,example glossary
+glossary.id,42
+text,"a, ""b""
+	c\ $d"
+unicode,é 😀
+key=a:b c," x"
+ok,true
+none,null
+1st,1.5
+`,
+		},
+		{
+			format: FormatTSV,
+			expected: `key	value
+glossary.title	example glossary
+glossary.id	42
+text	a, "b"\n\tc\\ $d
+unicode	é 😀
+key=a:b c	 x
+ok	true
+none	null
+1st	1.5
+`,
+		},
+		{
+			format: FormatDotenv,
+			expected: `GLOSSARY_TITLE="example glossary"
+GLOSSARY_ID=42
+TEXT="a, \"b\"\n\tc\\ \$d"
+UNICODE="é 😀"
+KEY_A_B_C=" x"
+OK=true
+NONE=
+_1ST=1.5
+`,
+		},
+		{
+			format: FormatProperties,
+			expected: `glossary.title=example glossary
+glossary.id=42
+text=a, "b"\n\tc\\ $d
+unicode=\u00e9 \ud83d\ude00
+key\=a\:b\ c=\ x
+ok=true
+none=
+1st=1.5
+`,
+		},
+	}
+
+	for _, test := range tests {
+		var buf bytes.Buffer
+		w := NewWriter(&buf, test.format)
+		p := NewParserFast(nil, WithRawEmitter(w.EmitRaw))
+		require.NoError(t, p.ParseBytes([]byte(writerDoc)))
+		require.NoError(t, w.Close())
+		require.Equal(t, test.expected, buf.String(), "format %d", test.format)
+
+		// the three emitters write the same output
+		var typed bytes.Buffer
+		w = NewWriter(&typed, test.format)
+		p = NewParserFast(nil, WithTypedEmitter(w.EmitTyped))
+		require.NoError(t, p.ParseBytes([]byte(writerDoc)))
+		require.NoError(t, w.Close())
+		require.Equal(t, test.expected, typed.String())
+
+		var emitter bytes.Buffer
+		w = NewWriter(&emitter, test.format)
+		require.NoError(t, NewParserFast(w.Emit).ParseBytes([]byte(writerDoc)))
+		require.NoError(t, w.Close())
+		require.Equal(t, test.expected, emitter.String())
+	}
+}
+
+func TestWriterEmpty(t *testing.T) {
+	expected := map[Format]string{
+		FormatJSON:       "{}\n",
+		FormatCSV:        "key,value\n",
+		FormatTSV:        "key\tvalue\n",
+		FormatDotenv:     "",
+		FormatProperties: "",
+	}
+
+	for format, out := range expected {
+		var buf bytes.Buffer
+		w := NewWriter(&buf, format)
+		require.NoError(t, NewParserFast(w.Emit).ParseBytes([]byte(`{}`)))
+		require.NoError(t, w.Close())
+		require.Equal(t, out, buf.String())
+	}
+}
+
+func TestWriterJSONRepeatedKeys(t *testing.T) {
+	tests := []struct {
+		doc      string
+		opts     []Option
+		expected string
+	}{
+		{
+			doc:      `{"a": [1, 2]}`,
+			opts:     []Option{WithArrayMode(ArrayWildcard)},
+			expected: "{\n  \"a.[]\": 1,\n  \"a.[]\": 2\n}\n",
+		},
+		{
+			doc:      `{"a": 1, "a": 2}`,
+			opts:     []Option{WithDuplicateKeys(DuplicateAll)},
+			expected: "{\n  \"a\": 1,\n  \"a\": 2\n}\n",
+		},
+	}
+
+	for _, test := range tests {
+		var buf bytes.Buffer
+		w := NewWriter(&buf, FormatJSON)
+		require.NoError(t, NewParserFast(w.Emit, test.opts...).ParseBytes([]byte(test.doc)))
+		require.NoError(t, w.Close())
+		require.Equal(t, test.expected, buf.String())
+	}
+}
+
+type failWriter struct{}
+
+var errWrite = errors.New("write failed")
+
+func (failWriter) Write([]byte) (int, error) {
+	return 0, errWrite
+}
+
+func TestWriterError(t *testing.T) {
+	w := NewWriter(failWriter{}, FormatJSON)
+	w.w.Reset(failWriter{})
+
+	// the buffer is flushed when it is full, then parsing stops
+	var count int
+	p := NewParserFast(func(k string, v any) bool {
+		count++
+		return w.Emit(k, v)
+	})
+	err := p.ParseBytes([]byte(arrayDoc(10000)))
+	require.NoError(t, err)
+	require.Less(t, count, 40000)
+	require.ErrorIs(t, w.Close(), errWrite)
+}
+
+func TestWithOutput(t *testing.T) {
+	doc := `{"a": "x\n\"y\"", "b": [null, true, 1e21]}`
+	expected := `a = "x\n\"y\""
+b.0 = null
+b.1 = true
+b.2 = 1e+21
+`
+
+	for name, f := range flatteners {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			p := f(nil, WithOutput(&buf))
+			require.NoError(t, p.Parse(strings.NewReader(doc)))
+			require.Equal(t, expected, buf.String())
+		})
+	}
+
+	// a write error stops the parse and is returned
+	p := NewParserFast(nil, WithOutput(failWriter{}))
+	err := p.ParseBytes([]byte(arrayDoc(10000)))
+	require.ErrorIs(t, err, errWrite)
+}